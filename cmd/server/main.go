@@ -1,22 +1,47 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"expvar"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	// Time zones work even where the system has no zoneinfo database
+	_ "time/tzdata"
 
 	"miniflux-feishu/internal/handlers"
+	"miniflux-feishu/internal/services"
 
 	"github.com/gin-gonic/gin"
 )
 
+// App bundles the HTTP router with the background delivery workers and the
+// outbox they read from.
+type App struct {
+	Router     *gin.Engine
+	Dispatcher *services.Dispatcher
+	Outbox     *services.Outbox
+}
+
+func NewApp(router *gin.Engine, dispatcher *services.Dispatcher, outbox *services.Outbox) *App {
+	return &App{
+		Router:     router,
+		Dispatcher: dispatcher,
+		Outbox:     outbox,
+	}
+}
+
 func main() {
 	log.SetOutput(os.Stdout)
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
 	log.Println("Starting Miniflux-Feishu Integration Service...")
 
-	r, err := InitializeApp()
+	app, err := InitializeApp()
 	if err != nil {
 		log.Fatalf("Failed to initialize app: %v", err)
 	}
@@ -28,15 +53,43 @@ func main() {
 	}
 
 	log.Printf("Starting server on port %s", port)
-	log.Printf("Webhook endpoint: http://localhost:%s/webhook/miniflux?webhook_url=YOUR_FEISHU_WEBHOOK_URL", port)
+	log.Printf("Webhook endpoint: http://localhost:%s/webhook/miniflux/:destination", port)
 	log.Printf("Health check endpoint: http://localhost:%s/health", port)
+	log.Printf("Metrics endpoint: http://localhost:%s/debug/vars", port)
+
+	app.Dispatcher.Start()
 
-	if err := r.Run("0.0.0.0:" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	server := &http.Server{
+		Addr:    "0.0.0.0:" + port,
+		Handler: app.Router,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Println("Shutting down...")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// 先停止接收新的 webhook，再等待队列中的消息发送完成
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Failed to shut down server: %v", err)
+	}
+	if err := app.Dispatcher.Stop(ctx); err != nil {
+		log.Printf("Failed to deliver all queued entries, they will be resumed on next start: %v", err)
+	}
+	if err := app.Outbox.Close(); err != nil {
+		log.Printf("Failed to close outbox: %v", err)
 	}
 }
 
-func setupRouter(webhookHandler *handlers.WebhookHandler) *gin.Engine {
+func setupRouter(webhookHandler *handlers.WebhookHandler, adminHandler *handlers.AdminHandler, previewHandler *handlers.PreviewHandler) *gin.Engine {
 	if os.Getenv("GIN_MODE") == "" {
 		gin.SetMode(gin.ReleaseMode)
 	}
@@ -50,10 +103,26 @@ func setupRouter(webhookHandler *handlers.WebhookHandler) *gin.Engine {
 		})
 	})
 
+	// Metrics name destinations and feeds, so they need the admin token too
+	r.GET("/debug/vars", adminHandler.RequireToken, gin.WrapH(expvar.Handler()))
+
 	webhook := r.Group("/webhook")
 	webhook.Use(gin.Logger(), gin.Recovery())
 
 	webhook.POST("/miniflux", webhookHandler.HandleMinifluxWebhook)
+	webhook.POST("/miniflux/:destination", webhookHandler.HandleMinifluxWebhook)
+
+	admin := r.Group("/admin")
+	admin.Use(gin.Logger(), gin.Recovery(), adminHandler.RequireToken)
+
+	admin.GET("/dead-letters", adminHandler.ListDeadLetters)
+	admin.DELETE("/dead-letters", adminHandler.PurgeDeadLetters)
+	admin.POST("/dead-letters/replay", adminHandler.ReplayDeadLetters)
+	admin.GET("/dead-letters/:id", adminHandler.GetDeadLetter)
+	admin.DELETE("/dead-letters/:id", adminHandler.DeleteDeadLetter)
+	admin.POST("/dead-letters/:id/replay", adminHandler.ReplayDeadLetter)
+	admin.POST("/preview", previewHandler.PreviewMessage)
+	admin.POST("/preview/filter", previewHandler.PreviewFilter)
 
 	return r
 }
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"miniflux-feishu/internal/config"
 	"miniflux-feishu/internal/handlers"
 	"miniflux-feishu/internal/services"
 
@@ -12,16 +13,30 @@ import (
 )
 
 var ProviderSet = wire.NewSet(
+	config.Load,
 	services.NewFeishuService,
+	services.OpenOutbox,
+	services.NewDispatcher,
+	services.NewEntryRouter,
 	handlers.NewWebhookHandler,
+	handlers.NewAdminHandler,
+	handlers.NewPreviewHandler,
 	NewRouter,
+	NewApp,
+	wire.Bind(new(services.EntrySender), new(*services.FeishuService)),
+	wire.Bind(new(handlers.FeishuServiceInterface), new(*services.FeishuService)),
+	wire.Bind(new(handlers.DeliveryQueue), new(*services.Dispatcher)),
+	wire.Bind(new(handlers.EntryRouter), new(*services.EntryRouter)),
+	wire.Bind(new(handlers.DeadLetterStore), new(*services.Outbox)),
+	wire.Bind(new(handlers.DeadLetterReplayer), new(*services.Dispatcher)),
+	wire.Bind(new(handlers.MessageRenderer), new(*services.FeishuService)),
 )
 
-func NewRouter(webhookHandler *handlers.WebhookHandler) *gin.Engine {
-	return setupRouter(webhookHandler)
+func NewRouter(webhookHandler *handlers.WebhookHandler, adminHandler *handlers.AdminHandler, previewHandler *handlers.PreviewHandler) *gin.Engine {
+	return setupRouter(webhookHandler, adminHandler, previewHandler)
 }
 
-func InitializeApp() (*gin.Engine, error) {
+func InitializeApp() (*App, error) {
 	wire.Build(ProviderSet)
 	return nil, nil
 }
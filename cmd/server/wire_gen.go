@@ -9,23 +9,45 @@ package main
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/wire"
+	"miniflux-feishu/internal/config"
 	"miniflux-feishu/internal/handlers"
 	"miniflux-feishu/internal/services"
 )
 
 // Injectors from wire.go:
 
-func InitializeApp() (*gin.Engine, error) {
-	feishuService := services.NewFeishuService()
-	webhookHandler := handlers.NewWebhookHandler(feishuService)
-	engine := NewRouter(webhookHandler)
-	return engine, nil
+func InitializeApp() (*App, error) {
+	configConfig, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	feishuService := services.NewFeishuService(configConfig)
+	outbox, err := services.OpenOutbox(configConfig)
+	if err != nil {
+		return nil, err
+	}
+	dispatcher, err := services.NewDispatcher(feishuService, outbox, configConfig)
+	if err != nil {
+		return nil, err
+	}
+	entryRouter, err := services.NewEntryRouter(configConfig)
+	if err != nil {
+		return nil, err
+	}
+	webhookHandler := handlers.NewWebhookHandler(feishuService, dispatcher, entryRouter, configConfig)
+	adminHandler := handlers.NewAdminHandler(outbox, dispatcher, configConfig)
+	previewHandler := handlers.NewPreviewHandler(feishuService, configConfig)
+	engine := NewRouter(webhookHandler, adminHandler, previewHandler)
+	app := NewApp(engine, dispatcher, outbox)
+	return app, nil
 }
 
 // wire.go:
 
-var ProviderSet = wire.NewSet(services.NewFeishuService, handlers.NewWebhookHandler, NewRouter)
+var ProviderSet = wire.NewSet(config.Load, services.NewFeishuService, services.OpenOutbox, services.NewDispatcher, services.NewEntryRouter, handlers.NewWebhookHandler, handlers.NewAdminHandler, handlers.NewPreviewHandler, NewRouter,
+	NewApp, wire.Bind(new(services.EntrySender), new(*services.FeishuService)), wire.Bind(new(handlers.FeishuServiceInterface), new(*services.FeishuService)), wire.Bind(new(handlers.DeliveryQueue), new(*services.Dispatcher)), wire.Bind(new(handlers.EntryRouter), new(*services.EntryRouter)), wire.Bind(new(handlers.DeadLetterStore), new(*services.Outbox)), wire.Bind(new(handlers.DeadLetterReplayer), new(*services.Dispatcher)), wire.Bind(new(handlers.MessageRenderer), new(*services.FeishuService)),
+)
 
-func NewRouter(webhookHandler *handlers.WebhookHandler) *gin.Engine {
-	return setupRouter(webhookHandler)
+func NewRouter(webhookHandler *handlers.WebhookHandler, adminHandler *handlers.AdminHandler, previewHandler *handlers.PreviewHandler) *gin.Engine {
+	return setupRouter(webhookHandler, adminHandler, previewHandler)
 }
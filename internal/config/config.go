@@ -0,0 +1,770 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"miniflux-feishu/internal/expression"
+	"miniflux-feishu/internal/i18n"
+	"miniflux-feishu/internal/templates"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the server-side configuration of the service.
+type Config struct {
+	Miniflux     MinifluxConfig          `yaml:"miniflux"`
+	Destinations map[string]*Destination `yaml:"destinations"`
+	// Routing picks the destinations of entries posted to /webhook/miniflux
+	// without a destination.
+	Routing RoutingConfig `yaml:"routing"`
+	// LegacyWebhookURL accepts the Feishu bot URL from the webhook_url query
+	// parameter. The URL contains the bot token, so prefer named destinations.
+	LegacyWebhookURL bool           `yaml:"legacy_webhook_url"`
+	Security         SecurityConfig `yaml:"security"`
+	Delivery         DeliveryConfig `yaml:"delivery"`
+	Outbox           OutboxConfig   `yaml:"outbox"`
+	Admin            AdminConfig    `yaml:"admin"`
+	App              AppConfig      `yaml:"app"`
+	Images           ImagesConfig   `yaml:"images"`
+	// Locale is the language of the message labels for destinations that set
+	// none, i18n.DefaultLocale by default.
+	Locale string `yaml:"locale"`
+	// TimeZone is the IANA name of the time zone publish times are shown in,
+	// for destinations that set none. Times are shown as Miniflux sent them
+	// when it is empty.
+	TimeZone string         `yaml:"time_zone"`
+	Location *time.Location `yaml:"-"`
+}
+
+// RoutingConfig sends entries to destinations by rules, so that one Miniflux
+// webhook can feed many Feishu groups.
+type RoutingConfig struct {
+	// Rules are all evaluated, an entry goes to the destinations of every
+	// rule it matches.
+	Rules []RoutingRule `yaml:"rules"`
+	// Fallback receives the entries no rule matches. They are dropped when it
+	// is empty.
+	Fallback string `yaml:"fallback"`
+}
+
+// Enabled reports whether routing is configured.
+func (r RoutingConfig) Enabled() bool {
+	return len(r.Rules) > 0 || r.Fallback != ""
+}
+
+// RoutingRule routes the entries it matches to one or more destinations.
+type RoutingRule struct {
+	Name         string     `yaml:"name"`
+	Match        RouteMatch `yaml:"match"`
+	Destinations []string   `yaml:"destinations"`
+}
+
+// RouteMatch lists the conditions of a rule. Every condition that is set has
+// to match, and a list matches when any of its values does.
+type RouteMatch struct {
+	FeedIDs    []int64  `yaml:"feed_ids"`
+	Categories []string `yaml:"categories"`
+	// FeedHosts matches the host of the feed URL and its subdomains.
+	FeedHosts []string `yaml:"feed_hosts"`
+	Tags      []string `yaml:"tags"`
+	// Title is a regular expression matched against the entry title.
+	Title string `yaml:"title"`
+}
+
+// Empty reports whether no condition is set.
+func (m RouteMatch) Empty() bool {
+	return len(m.FeedIDs) == 0 && len(m.Categories) == 0 && len(m.FeedHosts) == 0 && len(m.Tags) == 0 && m.Title == ""
+}
+
+// AppConfig holds the credentials of a Feishu app, needed for the open
+// platform APIs that custom bots cannot call, such as image upload.
+type AppConfig struct {
+	AppID     string `yaml:"app_id"`
+	AppSecret string `yaml:"app_secret"`
+	// BaseURL is the open platform endpoint, DefaultAppBaseURL when empty.
+	BaseURL string `yaml:"base_url"`
+}
+
+// DefaultAppBaseURL is the Feishu open platform endpoint.
+const DefaultAppBaseURL = "https://open.feishu.cn"
+
+// Configured reports whether app credentials are set.
+func (a AppConfig) Configured() bool {
+	return a.AppID != "" && a.AppSecret != ""
+}
+
+// ImagesConfig limits the lead images downloaded for cards.
+type ImagesConfig struct {
+	// MaxSize is the largest image downloaded, in bytes.
+	MaxSize int64 `yaml:"max_size"`
+	// CacheSize is how many uploaded image keys are remembered.
+	CacheSize int `yaml:"cache_size"`
+}
+
+// AdminConfig protects the admin API.
+type AdminConfig struct {
+	// Token is expected as "Authorization: Bearer <token>". The admin API is
+	// disabled while it is empty.
+	Token string `yaml:"token"`
+}
+
+// OutboxConfig controls the on-disk store that accepted entries are written
+// to before the webhook is answered.
+type OutboxConfig struct {
+	Path string `yaml:"path"`
+	// Retention is how long finished deliveries are kept before being purged.
+	// Pending entries are never purged.
+	Retention time.Duration `yaml:"retention"`
+}
+
+// DeliveryConfig sizes the worker pool that sends entries to Feishu.
+type DeliveryConfig struct {
+	Workers   int `yaml:"workers"`
+	QueueSize int `yaml:"queue_size"`
+	// RetryAfter is suggested to Miniflux when the queue is full.
+	RetryAfter time.Duration `yaml:"retry_after"`
+	// Retry is the default retry policy of destinations that set none.
+	Retry RetryPolicy `yaml:"retry"`
+	// RateLimit is the default rate limit of destinations that set none.
+	RateLimit RateLimit `yaml:"rate_limit"`
+}
+
+// DefaultRateLimit matches the quota of a Feishu custom bot.
+var DefaultRateLimit = RateLimit{
+	PerSecond: 5,
+	PerMinute: 100,
+}
+
+// RateLimit caps how many messages are sent to one bot.
+type RateLimit struct {
+	PerSecond int `yaml:"per_second"`
+	PerMinute int `yaml:"per_minute"`
+}
+
+// WithDefaults returns a copy of the limit with unset fields taken from
+// fallback.
+func (r RateLimit) WithDefaults(fallback RateLimit) RateLimit {
+	if r.PerSecond == 0 {
+		r.PerSecond = fallback.PerSecond
+	}
+	if r.PerMinute == 0 {
+		r.PerMinute = fallback.PerMinute
+	}
+	return r
+}
+
+// DefaultRetryPolicy is used for any RetryPolicy field left unset.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: time.Second,
+	MaxBackoff:     time.Minute,
+}
+
+// RetryPolicy controls how often and how fast failed sends are retried.
+type RetryPolicy struct {
+	// MaxAttempts counts the first attempt, so 1 disables retries.
+	MaxAttempts    int           `yaml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+}
+
+// WithDefaults returns a copy of the policy with unset fields taken from
+// fallback.
+func (p RetryPolicy) WithDefaults(fallback RetryPolicy) RetryPolicy {
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = fallback.MaxAttempts
+	}
+	if p.InitialBackoff == 0 {
+		p.InitialBackoff = fallback.InitialBackoff
+	}
+	if p.MaxBackoff == 0 {
+		p.MaxBackoff = fallback.MaxBackoff
+	}
+	return p
+}
+
+// DefaultAllowedHosts are the Feishu and Lark open platform hosts.
+var DefaultAllowedHosts = []string{"open.feishu.cn", "open.larksuite.com"}
+
+// SecurityConfig restricts where outbound webhook requests may go.
+type SecurityConfig struct {
+	// AllowedHosts lists the hostnames webhooks may be sent to. "*" allows any
+	// host, an empty list falls back to DefaultAllowedHosts.
+	AllowedHosts []string `yaml:"allowed_hosts"`
+	// AllowPrivateNetworks disables the loopback/private/link-local address
+	// check. Only meant for local development.
+	AllowPrivateNetworks bool `yaml:"allow_private_networks"`
+}
+
+// MinifluxConfig controls how incoming Miniflux webhooks are authenticated.
+type MinifluxConfig struct {
+	// RequireSignature rejects requests that carry no X-Miniflux-Signature header.
+	RequireSignature bool             `yaml:"require_signature"`
+	Sources          []MinifluxSource `yaml:"sources"`
+}
+
+// MinifluxSource is a Miniflux instance (or user) allowed to call the webhook,
+// identified by the secret shown in its webhook integration settings.
+type MinifluxSource struct {
+	Name   string `yaml:"name"`
+	Secret string `yaml:"secret"`
+}
+
+// Destination is a Feishu custom bot that entries can be delivered to.
+type Destination struct {
+	Name       string `yaml:"-"`
+	WebhookURL string `yaml:"webhook_url"`
+	// Legacy marks destinations built from the webhook_url query parameter.
+	Legacy bool `yaml:"-"`
+	// Secret is the key of the bot's "签名校验" security setting.
+	Secret    string      `yaml:"secret"`
+	Retry     RetryPolicy `yaml:"retry"`
+	RateLimit RateLimit   `yaml:"rate_limit"`
+	// Format selects the message type, FormatText when empty.
+	Format string     `yaml:"format"`
+	Card   CardConfig `yaml:"card"`
+	// LinkFootnotes lists the URLs of links in the summary after it, for the
+	// text format. Cards keep their links clickable instead.
+	LinkFootnotes bool `yaml:"link_footnotes"`
+	// Filter selects which entries are forwarded to the destination.
+	Filter FilterConfig `yaml:"filter"`
+	// Locale overrides the configured default locale of message labels.
+	Locale string `yaml:"locale"`
+	// TimeZone overrides the configured default time zone. Location is the
+	// resolved time zone, nil to keep times as Miniflux sent them.
+	TimeZone string         `yaml:"time_zone"`
+	Location *time.Location `yaml:"-"`
+	// Metadata adds the author, tags, reading time, publish time and comments
+	// link to text and post messages, and the reading time and tags to cards.
+	Metadata bool `yaml:"metadata"`
+	// Summary controls how the entry excerpt is shortened.
+	Summary SummaryConfig `yaml:"summary"`
+	// Mentions @mentions users in the messages of matching entries.
+	Mentions MentionConfig `yaml:"mentions"`
+	// Template replaces the built-in layout of the format. It renders the JSON
+	// sent as "content" (or as "card" for cards).
+	Template string `yaml:"template"`
+}
+
+// DefaultMentionDailyLimit is the number of messages with mentions a
+// destination sends per day when it sets no limit.
+const DefaultMentionDailyLimit = 10
+
+// MentionConfig lists the mention rules of a destination. Mentions are added
+// to text, post and card messages, not to templates.
+type MentionConfig struct {
+	Rules []MentionRule `yaml:"rules"`
+	// DailyLimit caps the messages with mentions per day, in the time zone of
+	// the destination. Later messages are sent without mentions.
+	// DefaultMentionDailyLimit when zero.
+	DailyLimit int `yaml:"daily_limit"`
+}
+
+// MentionRule mentions everyone or the listed users when an entry matches its
+// filter. A rule without filter matches every entry.
+type MentionRule struct {
+	Filter FilterConfig `yaml:"filter"`
+	All    bool         `yaml:"all"`
+	// UserIDs are the open IDs (ou_...) of the users to mention.
+	UserIDs []string `yaml:"user_ids"`
+}
+
+// Message formats a destination can use.
+const (
+	FormatText = "text"
+	FormatPost = "post"
+	FormatCard = "card"
+)
+
+// CardHeaderColors are the header templates supported by Feishu cards.
+var CardHeaderColors = []string{
+	"blue", "wathet", "turquoise", "green", "yellow", "orange",
+	"red", "carmine", "violet", "purple", "indigo", "grey",
+}
+
+// CardConfig customizes interactive card messages.
+type CardConfig struct {
+	// HeaderColors maps a category or feed title to a header color. Other
+	// feeds get a color derived from their category or title.
+	HeaderColors map[string]string `yaml:"header_colors"`
+	// LeadImage shows the entry's lead image at the top of the card. The
+	// image is uploaded with the app credentials.
+	LeadImage bool `yaml:"lead_image"`
+}
+
+// FilterConfig restricts the entries sent to a destination. An empty filter
+// lets everything through.
+type FilterConfig struct {
+	// EnclosureTypes only forwards entries with an enclosure of one of these
+	// MIME types. A type without a subtype, such as "audio", matches all of them.
+	EnclosureTypes []string `yaml:"enclosure_types"`
+	// Include only forwards entries containing one of these keywords or
+	// matching one of IncludePatterns.
+	Include         []string `yaml:"include"`
+	IncludePatterns []string `yaml:"include_patterns"`
+	// Exclude drops entries containing one of these keywords or matching one
+	// of ExcludePatterns, even when they are included.
+	Exclude         []string `yaml:"exclude"`
+	ExcludePatterns []string `yaml:"exclude_patterns"`
+	// Fields are the parts of an entry searched for keywords and patterns,
+	// see FilterFields. All of them when empty.
+	Fields []string `yaml:"fields"`
+	// CaseSensitive turns off case folding for keywords and patterns.
+	CaseSensitive bool `yaml:"case_sensitive"`
+	// WholeWord only matches keywords that are not part of a longer word.
+	// Patterns are used as written.
+	WholeWord bool `yaml:"whole_word"`
+	// Expression only forwards entries for which it evaluates to true, see
+	// the expression package for the available fields.
+	Expression string `yaml:"expression"`
+
+	// IncludeRegexps and ExcludeRegexps are the compiled keywords and
+	// patterns, Program the compiled expression. They are set by Compile.
+	IncludeRegexps []*regexp.Regexp    `yaml:"-"`
+	ExcludeRegexps []*regexp.Regexp    `yaml:"-"`
+	Program        *expression.Program `yaml:"-"`
+}
+
+// Entry fields searched by keyword filters.
+const (
+	FilterFieldTitle   = "title"
+	FilterFieldContent = "content"
+	FilterFieldAuthor  = "author"
+	FilterFieldTags    = "tags"
+)
+
+// FilterFields lists the valid values of FilterConfig.Fields.
+var FilterFields = []string{FilterFieldTitle, FilterFieldContent, FilterFieldAuthor, FilterFieldTags}
+
+// Compile turns the keywords and patterns into the regular expressions used
+// for matching and compiles the expression. Load calls it for every
+// destination.
+func (f *FilterConfig) Compile() error {
+	include, err := f.compile(f.Include, f.IncludePatterns)
+	if err != nil {
+		return err
+	}
+	exclude, err := f.compile(f.Exclude, f.ExcludePatterns)
+	if err != nil {
+		return err
+	}
+	var program *expression.Program
+	if strings.TrimSpace(f.Expression) != "" {
+		program, err = expression.Compile(f.Expression)
+		if err != nil {
+			return fmt.Errorf("invalid expression: %w", err)
+		}
+	}
+	f.IncludeRegexps, f.ExcludeRegexps, f.Program = include, exclude, program
+	return nil
+}
+
+func (f *FilterConfig) compile(keywords, patterns []string) ([]*regexp.Regexp, error) {
+	flags := "(?i)"
+	if f.CaseSensitive {
+		flags = ""
+	}
+	var res []*regexp.Regexp
+	for _, keyword := range keywords {
+		keyword = strings.TrimSpace(keyword)
+		if keyword == "" {
+			return nil, errors.New("empty keyword")
+		}
+		expr := regexp.QuoteMeta(keyword)
+		if f.WholeWord {
+			expr = wholeWord(keyword, expr)
+		}
+		res = append(res, regexp.MustCompile(flags+expr))
+	}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(flags + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// Word boundaries match the start or end of the text or a character that
+// cannot be part of a word. Go's \b only knows ASCII words.
+const (
+	wordStart = `(?:^|[^\p{L}\p{N}_])`
+	wordEnd   = `(?:$|[^\p{L}\p{N}_])`
+)
+
+// wholeWord surrounds the quoted keyword with word boundaries. Edges in
+// scripts written without spaces, such as Chinese, are left open, since a
+// boundary would never match there.
+func wholeWord(keyword, expr string) string {
+	first, _ := utf8.DecodeRuneInString(keyword)
+	last, _ := utf8.DecodeLastRuneInString(keyword)
+	if isSpacedWordRune(first) {
+		expr = wordStart + expr
+	}
+	if isSpacedWordRune(last) {
+		expr += wordEnd
+	}
+	return expr
+}
+
+func isSpacedWordRune(r rune) bool {
+	if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+		return false
+	}
+	return !unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Thai)
+}
+
+// DefaultSummary keeps the first 300 characters of an entry.
+var DefaultSummary = SummaryConfig{
+	Length:   300,
+	Ellipsis: "...",
+}
+
+// SummaryConfig controls how the entry content is cut down to an excerpt.
+type SummaryConfig struct {
+	// Length is the maximum excerpt length, without the ellipsis.
+	Length   int    `yaml:"length"`
+	Ellipsis string `yaml:"ellipsis"`
+	// DisplayWidth measures the length in display columns, so that CJK
+	// characters count double and card columns line up.
+	DisplayWidth bool `yaml:"display_width"`
+}
+
+// WithDefaults returns a copy of the summary settings with unset fields taken
+// from fallback.
+func (s SummaryConfig) WithDefaults(fallback SummaryConfig) SummaryConfig {
+	if s.Length == 0 {
+		s.Length = fallback.Length
+	}
+	if s.Ellipsis == "" {
+		s.Ellipsis = fallback.Ellipsis
+	}
+	return s
+}
+
+// Load reads the configuration file pointed to by CONFIG_FILE (if any) and
+// applies environment variable overrides on top of it.
+func Load() (*Config, error) {
+	cfg := &Config{
+		Delivery: DeliveryConfig{
+			Workers:    4,
+			QueueSize:  1000,
+			RetryAfter: 30 * time.Second,
+			Retry:      DefaultRetryPolicy,
+			RateLimit:  DefaultRateLimit,
+		},
+		Outbox: OutboxConfig{
+			Path:      "data/outbox.db",
+			Retention: 7 * 24 * time.Hour,
+		},
+		App: AppConfig{
+			BaseURL: DefaultAppBaseURL,
+		},
+		Images: ImagesConfig{
+			MaxSize:   5 << 20,
+			CacheSize: 1000,
+		},
+		Locale: i18n.DefaultLocale,
+	}
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %w", err)
+		}
+	}
+
+	if secret := os.Getenv("MINIFLUX_WEBHOOK_SECRET"); secret != "" {
+		cfg.Miniflux.Sources = append(cfg.Miniflux.Sources, MinifluxSource{Name: "default", Secret: secret})
+	}
+	if v := os.Getenv("MINIFLUX_REQUIRE_SIGNATURE"); v != "" {
+		required, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MINIFLUX_REQUIRE_SIGNATURE: %w", err)
+		}
+		cfg.Miniflux.RequireSignature = required
+	}
+	if v := os.Getenv("ALLOWED_WEBHOOK_HOSTS"); v != "" {
+		cfg.Security.AllowedHosts = nil
+		for _, host := range strings.Split(v, ",") {
+			if host = strings.TrimSpace(host); host != "" {
+				cfg.Security.AllowedHosts = append(cfg.Security.AllowedHosts, host)
+			}
+		}
+	}
+	if v := os.Getenv("DELIVERY_WORKERS"); v != "" {
+		workers, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DELIVERY_WORKERS: %w", err)
+		}
+		cfg.Delivery.Workers = workers
+	}
+	if v := os.Getenv("DELIVERY_QUEUE_SIZE"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DELIVERY_QUEUE_SIZE: %w", err)
+		}
+		cfg.Delivery.QueueSize = size
+	}
+	if v := os.Getenv("OUTBOX_PATH"); v != "" {
+		cfg.Outbox.Path = v
+	}
+	if v := os.Getenv("ADMIN_TOKEN"); v != "" {
+		cfg.Admin.Token = v
+	}
+	if v := os.Getenv("DEFAULT_LOCALE"); v != "" {
+		cfg.Locale = v
+	}
+	if v := os.Getenv("DEFAULT_TIME_ZONE"); v != "" {
+		cfg.TimeZone = v
+	}
+	if v := os.Getenv("FEISHU_APP_ID"); v != "" {
+		cfg.App.AppID = v
+	}
+	if v := os.Getenv("FEISHU_APP_SECRET"); v != "" {
+		cfg.App.AppSecret = v
+	}
+	if v := os.Getenv("LEGACY_WEBHOOK_URL"); v != "" {
+		legacy, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LEGACY_WEBHOOK_URL: %w", err)
+		}
+		cfg.LegacyWebhookURL = legacy
+	}
+
+	if cfg.TimeZone != "" {
+		location, err := time.LoadLocation(cfg.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time_zone: %w", err)
+		}
+		cfg.Location = location
+	}
+
+	for name, dest := range cfg.Destinations {
+		if dest == nil {
+			dest = &Destination{}
+			cfg.Destinations[name] = dest
+		}
+		dest.Name = name
+		dest.Retry = dest.Retry.WithDefaults(cfg.Delivery.Retry.WithDefaults(DefaultRetryPolicy))
+		dest.RateLimit = dest.RateLimit.WithDefaults(cfg.Delivery.RateLimit.WithDefaults(DefaultRateLimit))
+		dest.Location = cfg.Location
+		if dest.TimeZone != "" {
+			location, err := time.LoadLocation(dest.TimeZone)
+			if err != nil {
+				return nil, fmt.Errorf("destination %q: invalid time_zone: %w", name, err)
+			}
+			dest.Location = location
+		}
+		if err := dest.Filter.Compile(); err != nil {
+			return nil, fmt.Errorf("destination %q: filter: %w", name, err)
+		}
+		for i := range dest.Mentions.Rules {
+			if err := dest.Mentions.Rules[i].Filter.Compile(); err != nil {
+				return nil, fmt.Errorf("destination %q: mention rule %d: filter: %w", name, i, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks the configuration for inconsistencies.
+func (c *Config) Validate() error {
+	for i, source := range c.Miniflux.Sources {
+		if source.Secret == "" {
+			return fmt.Errorf("miniflux source %d (%q) has an empty secret", i, source.Name)
+		}
+	}
+	if c.Miniflux.RequireSignature && len(c.Miniflux.Sources) == 0 {
+		return fmt.Errorf("miniflux.require_signature is enabled but no source secrets are configured")
+	}
+	if c.Delivery.Workers < 1 {
+		return fmt.Errorf("delivery.workers must be at least 1")
+	}
+	if c.Delivery.QueueSize < 1 {
+		return fmt.Errorf("delivery.queue_size must be at least 1")
+	}
+	if c.Outbox.Path == "" {
+		return fmt.Errorf("outbox.path is required")
+	}
+	if c.App.Configured() {
+		u, err := url.Parse(c.App.BaseURL)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("app.base_url %q is invalid", c.App.BaseURL)
+		}
+		if !c.Security.HostAllowed(u.Hostname()) {
+			return fmt.Errorf("app.base_url: host %q is not in security.allowed_hosts", u.Hostname())
+		}
+	}
+	if _, ok := i18n.Lookup(c.Locale); !ok && c.Locale != "" {
+		return fmt.Errorf("unsupported locale %q, use one of %s", c.Locale, strings.Join(i18n.Supported(), ", "))
+	}
+	if c.Images.MaxSize < 1 {
+		return fmt.Errorf("images.max_size must be at least 1")
+	}
+	if c.Images.CacheSize < 1 {
+		return fmt.Errorf("images.cache_size must be at least 1")
+	}
+	if err := c.validateRouting(); err != nil {
+		return err
+	}
+	for name, dest := range c.Destinations {
+		if dest.WebhookURL == "" {
+			return fmt.Errorf("destination %q has no webhook_url", name)
+		}
+		if dest.Retry.MaxAttempts < 1 {
+			return fmt.Errorf("destination %q: retry.max_attempts must be at least 1", name)
+		}
+		if dest.RateLimit.PerSecond < 1 || dest.RateLimit.PerMinute < 1 {
+			return fmt.Errorf("destination %q: rate_limit values must be at least 1", name)
+		}
+		switch dest.Format {
+		case "", FormatText, FormatPost, FormatCard:
+		default:
+			return fmt.Errorf("destination %q: unknown format %q", name, dest.Format)
+		}
+		if err := dest.Filter.validate(); err != nil {
+			return fmt.Errorf("destination %q: filter: %w", name, err)
+		}
+		if err := dest.Mentions.validate(); err != nil {
+			return fmt.Errorf("destination %q: %w", name, err)
+		}
+		if dest.Locale != "" {
+			if _, ok := i18n.Lookup(dest.Locale); !ok {
+				return fmt.Errorf("destination %q: unsupported locale %q, use one of %s", name, dest.Locale, strings.Join(i18n.Supported(), ", "))
+			}
+		}
+		if dest.Summary.Length < 0 {
+			return fmt.Errorf("destination %q: summary.length must not be negative", name)
+		}
+		if dest.Card.LeadImage && !c.App.Configured() {
+			return fmt.Errorf("destination %q: card.lead_image requires app.app_id and app.app_secret", name)
+		}
+		for key, color := range dest.Card.HeaderColors {
+			if !slices.Contains(CardHeaderColors, color) {
+				return fmt.Errorf("destination %q: unknown card header color %q for %q", name, color, key)
+			}
+		}
+		if dest.Template != "" {
+			if err := templates.Validate(name, dest.Template); err != nil {
+				return fmt.Errorf("destination %q: invalid template: %w", name, err)
+			}
+		}
+		u, err := url.Parse(dest.WebhookURL)
+		if err != nil {
+			return fmt.Errorf("destination %q has an invalid webhook_url: %w", name, err)
+		}
+		if !c.Security.HostAllowed(u.Hostname()) {
+			return fmt.Errorf("destination %q: host %q is not in security.allowed_hosts", name, u.Hostname())
+		}
+	}
+	return nil
+}
+
+func (c *Config) validateRouting() error {
+	for i, rule := range c.Routing.Rules {
+		name := rule.Name
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		if rule.Match.Empty() {
+			return fmt.Errorf("routing rule %q has no match conditions", name)
+		}
+		if rule.Match.Title != "" {
+			if _, err := regexp.Compile(rule.Match.Title); err != nil {
+				return fmt.Errorf("routing rule %q: invalid title pattern: %w", name, err)
+			}
+		}
+		if len(rule.Destinations) == 0 {
+			return fmt.Errorf("routing rule %q has no destinations", name)
+		}
+		for _, dest := range rule.Destinations {
+			if _, ok := c.Destinations[dest]; !ok {
+				return fmt.Errorf("routing rule %q: unknown destination %q", name, dest)
+			}
+		}
+	}
+	if fallback := c.Routing.Fallback; fallback != "" {
+		if _, ok := c.Destinations[fallback]; !ok {
+			return fmt.Errorf("routing.fallback: unknown destination %q", fallback)
+		}
+	}
+	return nil
+}
+
+func (f FilterConfig) validate() error {
+	for _, t := range f.EnclosureTypes {
+		if strings.TrimSpace(t) == "" {
+			return errors.New("enclosure_types contains an empty type")
+		}
+	}
+	for _, field := range f.Fields {
+		if !slices.Contains(FilterFields, field) {
+			return fmt.Errorf("unknown field %q, use one of %s", field, strings.Join(FilterFields, ", "))
+		}
+	}
+	return nil
+}
+
+func (m MentionConfig) validate() error {
+	if m.DailyLimit < 0 {
+		return errors.New("mentions.daily_limit must not be negative")
+	}
+	for i, rule := range m.Rules {
+		if !rule.All && len(rule.UserIDs) == 0 {
+			return fmt.Errorf("mention rule %d mentions nobody, set all or user_ids", i)
+		}
+		for _, id := range rule.UserIDs {
+			if strings.TrimSpace(id) == "" {
+				return fmt.Errorf("mention rule %d has an empty user id", i)
+			}
+		}
+		if err := rule.Filter.validate(); err != nil {
+			return fmt.Errorf("mention rule %d: filter: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// HostAllowed reports whether webhooks may be sent to the given hostname.
+func (s SecurityConfig) HostAllowed(host string) bool {
+	allowed := s.AllowedHosts
+	if len(allowed) == 0 {
+		allowed = DefaultAllowedHosts
+	}
+	for _, h := range allowed {
+		if h == "*" || strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// DestinationByURL returns the configured destination using the given webhook
+// URL, or nil if there is none.
+func (c *Config) DestinationByURL(webhookURL string) *Destination {
+	for _, dest := range c.Destinations {
+		if dest.WebhookURL == webhookURL {
+			return dest
+		}
+	}
+	return nil
+}
@@ -0,0 +1,367 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoad_FromFileAndEnv(t *testing.T) {
+	path := writeConfigFile(t, `
+miniflux:
+  require_signature: false
+  sources:
+    - name: home
+      secret: home-secret
+destinations:
+  team:
+    webhook_url: https://open.feishu.cn/open-apis/bot/v2/hook/team
+    secret: bot-secret
+    retry:
+      max_attempts: 3
+delivery:
+  retry:
+    initial_backoff: 2s
+`)
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("MINIFLUX_WEBHOOK_SECRET", "env-secret")
+	t.Setenv("MINIFLUX_REQUIRE_SIGNATURE", "true")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if !cfg.Miniflux.RequireSignature {
+		t.Errorf("Expected MINIFLUX_REQUIRE_SIGNATURE to enable strict mode")
+	}
+
+	if len(cfg.Miniflux.Sources) != 2 {
+		t.Fatalf("Expected 2 sources, got %d", len(cfg.Miniflux.Sources))
+	}
+
+	if cfg.Miniflux.Sources[0].Name != "home" || cfg.Miniflux.Sources[0].Secret != "home-secret" {
+		t.Errorf("Unexpected first source: %+v", cfg.Miniflux.Sources[0])
+	}
+
+	if cfg.Miniflux.Sources[1].Name != "default" || cfg.Miniflux.Sources[1].Secret != "env-secret" {
+		t.Errorf("Unexpected second source: %+v", cfg.Miniflux.Sources[1])
+	}
+
+	dest := cfg.DestinationByURL("https://open.feishu.cn/open-apis/bot/v2/hook/team")
+	if dest == nil {
+		t.Fatalf("Expected destination to be found by webhook URL")
+	}
+	if dest.Name != "team" || dest.Secret != "bot-secret" {
+		t.Errorf("Unexpected destination: %+v", dest)
+	}
+
+	// Unset retry fields come from delivery.retry, then from the built-in defaults
+	expectedRetry := RetryPolicy{MaxAttempts: 3, InitialBackoff: 2 * time.Second, MaxBackoff: time.Minute}
+	if dest.Retry != expectedRetry {
+		t.Errorf("Expected retry policy %+v, got %+v", expectedRetry, dest.Retry)
+	}
+
+	if dest.RateLimit != DefaultRateLimit {
+		t.Errorf("Expected default rate limit %+v, got %+v", DefaultRateLimit, dest.RateLimit)
+	}
+}
+
+func TestLoad_TimeZone(t *testing.T) {
+	path := writeConfigFile(t, `
+time_zone: Asia/Shanghai
+destinations:
+  team:
+    webhook_url: https://open.feishu.cn/open-apis/bot/v2/hook/team
+  us:
+    webhook_url: https://open.feishu.cn/open-apis/bot/v2/hook/us
+    time_zone: America/New_York
+`)
+	t.Setenv("CONFIG_FILE", path)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if location := cfg.Destinations["team"].Location; location == nil || location.String() != "Asia/Shanghai" {
+		t.Errorf("Expected the default time zone, got %v", location)
+	}
+	if location := cfg.Destinations["us"].Location; location == nil || location.String() != "America/New_York" {
+		t.Errorf("Expected the destination time zone, got %v", location)
+	}
+}
+
+func TestLoad_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{
+			name: "strict mode without secrets",
+			content: `
+miniflux:
+  require_signature: true
+`,
+		},
+		{
+			name: "destination without webhook URL",
+			content: `
+destinations:
+  team:
+    secret: bot-secret
+`,
+		},
+		{
+			name: "destination outside the default allowlist",
+			content: `
+destinations:
+  team:
+    webhook_url: https://hooks.example.com/webhook
+`,
+		},
+		{
+			name: "unknown format",
+			content: `
+destinations:
+  team:
+    webhook_url: https://open.feishu.cn/open-apis/bot/v2/hook/abc
+    format: html
+`,
+		},
+		{
+			name: "unknown card header color",
+			content: `
+destinations:
+  team:
+    webhook_url: https://open.feishu.cn/open-apis/bot/v2/hook/abc
+    format: card
+    card:
+      header_colors:
+        News: pink
+`,
+		},
+		{
+			name: "negative summary length",
+			content: `
+destinations:
+  team:
+    webhook_url: https://open.feishu.cn/open-apis/bot/v2/hook/abc
+    summary:
+      length: -1
+`,
+		},
+		{
+			name: "lead image without app credentials",
+			content: `
+destinations:
+  team:
+    webhook_url: https://open.feishu.cn/open-apis/bot/v2/hook/abc
+    format: card
+    card:
+      lead_image: true
+`,
+		},
+		{
+			name: "unsupported destination locale",
+			content: `
+destinations:
+  team:
+    webhook_url: https://open.feishu.cn/open-apis/bot/v2/hook/abc
+    locale: xx-XX
+`,
+		},
+		{
+			name: "unsupported default locale",
+			content: `
+locale: klingon
+`,
+		},
+		{
+			name: "unknown destination time zone",
+			content: `
+destinations:
+  team:
+    webhook_url: https://open.feishu.cn/open-apis/bot/v2/hook/abc
+    time_zone: Mars/Olympus_Mons
+`,
+		},
+		{
+			name: "routing rule to unknown destination",
+			content: `
+destinations:
+  team:
+    webhook_url: https://open.feishu.cn/open-apis/bot/v2/hook/abc
+routing:
+  rules:
+    - match:
+        categories: [News]
+      destinations: [news]
+`,
+		},
+		{
+			name: "routing rule without conditions",
+			content: `
+destinations:
+  team:
+    webhook_url: https://open.feishu.cn/open-apis/bot/v2/hook/abc
+routing:
+  rules:
+    - destinations: [team]
+`,
+		},
+		{
+			name: "routing rule with invalid title pattern",
+			content: `
+destinations:
+  team:
+    webhook_url: https://open.feishu.cn/open-apis/bot/v2/hook/abc
+routing:
+  rules:
+    - match:
+        title: "(unclosed"
+      destinations: [team]
+`,
+		},
+		{
+			name: "unknown routing fallback",
+			content: `
+destinations:
+  team:
+    webhook_url: https://open.feishu.cn/open-apis/bot/v2/hook/abc
+routing:
+  fallback: other
+`,
+		},
+		{
+			name: "invalid filter pattern",
+			content: `
+destinations:
+  team:
+    webhook_url: https://open.feishu.cn/open-apis/bot/v2/hook/abc
+    filter:
+      include_patterns: ["CVE-(\\d+"]
+`,
+		},
+		{
+			name: "invalid filter expression",
+			content: `
+destinations:
+  team:
+    webhook_url: https://open.feishu.cn/open-apis/bot/v2/hook/abc
+    filter:
+      expression: "reading_time > 'five'"
+`,
+		},
+		{
+			name: "mention rule without users",
+			content: `
+destinations:
+  team:
+    webhook_url: https://open.feishu.cn/open-apis/bot/v2/hook/abc
+    mentions:
+      rules:
+        - filter:
+            include: [outage]
+`,
+		},
+		{
+			name: "negative mention daily limit",
+			content: `
+destinations:
+  team:
+    webhook_url: https://open.feishu.cn/open-apis/bot/v2/hook/abc
+    mentions:
+      daily_limit: -1
+      rules:
+        - all: true
+`,
+		},
+		{
+			name: "invalid mention filter expression",
+			content: `
+destinations:
+  team:
+    webhook_url: https://open.feishu.cn/open-apis/bot/v2/hook/abc
+    mentions:
+      rules:
+        - all: true
+          filter:
+            expression: "title"
+`,
+		},
+		{
+			name: "unknown filter field",
+			content: `
+destinations:
+  team:
+    webhook_url: https://open.feishu.cn/open-apis/bot/v2/hook/abc
+    filter:
+      include: [CVE]
+      fields: [summary]
+`,
+		},
+		{
+			name: "invalid template",
+			content: `
+destinations:
+  team:
+    webhook_url: https://open.feishu.cn/open-apis/bot/v2/hook/abc
+    template: '{"title": {{.Entry.Headline}}}'
+`,
+		},
+		{
+			name: "source with empty secret",
+			content: `
+miniflux:
+  sources:
+    - name: home
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", writeConfigFile(t, tt.content))
+
+			if _, err := Load(); err == nil {
+				t.Errorf("Expected an error, got nil")
+			}
+		})
+	}
+}
+
+func TestSecurityConfig_HostAllowed(t *testing.T) {
+	tests := []struct {
+		name         string
+		allowedHosts []string
+		host         string
+		expected     bool
+	}{
+		{name: "default feishu host", host: "open.feishu.cn", expected: true},
+		{name: "default lark host", host: "open.larksuite.com", expected: true},
+		{name: "default rejects other hosts", host: "hooks.example.com", expected: false},
+		{name: "custom list", allowedHosts: []string{"hooks.example.com"}, host: "hooks.example.com", expected: true},
+		{name: "custom list replaces defaults", allowedHosts: []string{"hooks.example.com"}, host: "open.feishu.cn", expected: false},
+		{name: "wildcard", allowedHosts: []string{"*"}, host: "anything.example.com", expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			security := SecurityConfig{AllowedHosts: tt.allowedHosts}
+			if result := security.HostAllowed(tt.host); result != tt.expected {
+				t.Errorf("Expected HostAllowed(%q) = %v, got %v", tt.host, tt.expected, result)
+			}
+		})
+	}
+}
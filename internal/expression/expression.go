@@ -0,0 +1,112 @@
+// Package expression compiles and evaluates filter expressions over Miniflux
+// entries, written in the expr language (https://expr-lang.org).
+package expression
+
+import (
+	"fmt"
+	"time"
+
+	"miniflux-feishu/internal/models"
+	"miniflux-feishu/internal/textutil"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+// Env is what an expression sees: the entry fields at the top level and the
+// feed under "feed", named as in the Miniflux webhook payload.
+type Env struct {
+	ID          int64  `expr:"id"`
+	FeedID      int64  `expr:"feed_id"`
+	Status      string `expr:"status"`
+	Title       string `expr:"title"`
+	URL         string `expr:"url"`
+	CommentsURL string `expr:"comments_url"`
+	// Content is the entry HTML converted to plain text.
+	Content     string      `expr:"content"`
+	Author      string      `expr:"author"`
+	Starred     bool        `expr:"starred"`
+	ReadingTime int         `expr:"reading_time"`
+	PublishedAt time.Time   `expr:"published_at"`
+	Tags        []string    `expr:"tags"`
+	Enclosures  []Enclosure `expr:"enclosures"`
+	Feed        Feed        `expr:"feed"`
+}
+
+type Feed struct {
+	ID      int64  `expr:"id"`
+	Title   string `expr:"title"`
+	FeedURL string `expr:"feed_url"`
+	SiteURL string `expr:"site_url"`
+	// Category has empty fields for uncategorized feeds.
+	Category Category `expr:"category"`
+}
+
+type Category struct {
+	ID    int64  `expr:"id"`
+	Title string `expr:"title"`
+}
+
+type Enclosure struct {
+	URL      string `expr:"url"`
+	MimeType string `expr:"mime_type"`
+	Size     int64  `expr:"size"`
+}
+
+// Program is a compiled expression.
+type Program struct {
+	source  string
+	program *vm.Program
+}
+
+// Compile parses and type checks an expression, which has to evaluate to a
+// boolean. Errors point at the offending part of the expression.
+func Compile(source string) (*Program, error) {
+	program, err := expr.Compile(source, expr.Env(Env{}), expr.AsBool())
+	if err != nil {
+		return nil, err
+	}
+	return &Program{source: source, program: program}, nil
+}
+
+// String returns the source of the expression.
+func (p *Program) String() string {
+	return p.source
+}
+
+// Match evaluates the expression for an entry.
+func (p *Program) Match(entry *models.WebhookEntry, feed *models.WebhookFeed) (bool, error) {
+	out, err := expr.Run(p.program, NewEnv(entry, feed))
+	if err != nil {
+		return false, fmt.Errorf("evaluate %q: %w", p.source, err)
+	}
+	return out.(bool), nil
+}
+
+// NewEnv builds the environment of an entry.
+func NewEnv(entry *models.WebhookEntry, feed *models.WebhookFeed) Env {
+	env := Env{
+		ID:          entry.ID,
+		FeedID:      entry.FeedID,
+		Status:      entry.Status,
+		Title:       entry.Title,
+		URL:         entry.URL,
+		CommentsURL: entry.CommentsURL,
+		Content:     textutil.HTMLToText(entry.Content),
+		Author:      entry.Author,
+		Starred:     entry.Starred,
+		ReadingTime: entry.ReadingTime,
+		PublishedAt: entry.Date,
+		Tags:        entry.Tags,
+	}
+	for _, e := range entry.Enclosures {
+		env.Enclosures = append(env.Enclosures, Enclosure{URL: e.URL, MimeType: e.MimeType, Size: e.Size})
+	}
+	if feed != nil {
+		env.Feed = Feed{ID: feed.ID, Title: feed.Title, FeedURL: feed.FeedURL, SiteURL: feed.SiteURL}
+		if feed.Category != nil {
+			env.Feed.Category = Category{ID: feed.Category.ID, Title: feed.Category.Title}
+		}
+	}
+	return env
+}
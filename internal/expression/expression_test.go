@@ -0,0 +1,97 @@
+package expression
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"miniflux-feishu/internal/models"
+)
+
+func TestProgram_Match(t *testing.T) {
+	feed := &models.WebhookFeed{
+		ID:       8,
+		Title:    "Papers",
+		FeedURL:  "https://arxiv.org/rss/cs",
+		Category: &models.WebhookCategory{ID: 2, Title: "Research"},
+	}
+	entry := &models.WebhookEntry{
+		ID:          231,
+		Title:       "Attention is all you need",
+		Content:     "<p>We propose the <b>Transformer</b>.</p>",
+		Author:      "Vaswani",
+		ReadingTime: 12,
+		Date:        time.Date(2023, 8, 17, 19, 29, 22, 0, time.UTC),
+		Tags:        []string{"ml", "nlp"},
+		Enclosures:  []models.WebhookEnclosure{{URL: "https://arxiv.org/paper.pdf", MimeType: "application/pdf", Size: 2 << 20}},
+	}
+
+	tests := []struct {
+		expression string
+		expected   bool
+	}{
+		{`reading_time > 5 && feed.category.title == 'Research' && !(title matches '(?i)weekly')`, true},
+		{`reading_time > 15`, false},
+		{`'nlp' in tags`, true},
+		{`content contains 'Transformer' && !(content contains '<b>')`, true},
+		{`any(enclosures, .mime_type == 'application/pdf' && .size > 1000000)`, true},
+		{`feed.feed_url startsWith 'https://arxiv.org/'`, true},
+		{`published_at.Year() == 2023`, true},
+		{`author == 'Hinton'`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			program, err := Compile(tt.expression)
+			if err != nil {
+				t.Fatalf("Failed to compile: %v", err)
+			}
+			result, err := program.Match(entry, feed)
+			if err != nil {
+				t.Fatalf("Failed to evaluate: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestProgram_Match_Uncategorized(t *testing.T) {
+	program, err := Compile(`feed.category.title == ''`)
+	if err != nil {
+		t.Fatalf("Failed to compile: %v", err)
+	}
+	result, err := program.Match(&models.WebhookEntry{}, &models.WebhookFeed{})
+	if err != nil {
+		t.Fatalf("Failed to evaluate: %v", err)
+	}
+	if !result {
+		t.Error("Expected an uncategorized feed to have an empty category title")
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   string
+	}{
+		{`reading_time > `, "unexpected token"},
+		{`readingtime > 5`, "unknown name readingtime"},
+		{`title > 5`, "invalid operation"},
+		{`title`, "expected bool"},
+		{`feed.categry.title == 'x'`, "categry"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := Compile(tt.expression)
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected error containing %q, got %q", tt.expected, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/models"
+	"miniflux-feishu/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeadLetterStore gives read and delete access to dead-lettered deliveries
+type DeadLetterStore interface {
+	DeadLetters(filter services.DeadLetterFilter) ([]*services.DeliveryJob, error)
+	DeadLetter(id uint64) (*services.DeliveryJob, error)
+	PurgeDeadLetters(ids []uint64) (int, error)
+}
+
+// DeadLetterReplayer queues dead-lettered deliveries again
+type DeadLetterReplayer interface {
+	Replay(ids []uint64) (int, error)
+}
+
+// AdminHandler serves the admin API used to inspect and replay dead letters.
+type AdminHandler struct {
+	store    DeadLetterStore
+	replayer DeadLetterReplayer
+	config   *config.Config
+}
+
+func NewAdminHandler(store DeadLetterStore, replayer DeadLetterReplayer, cfg *config.Config) *AdminHandler {
+	return &AdminHandler{
+		store:    store,
+		replayer: replayer,
+		config:   cfg,
+	}
+}
+
+// deadLetterSummary is the list view of a dead letter, without the payload.
+type deadLetterSummary struct {
+	ID          uint64    `json:"id"`
+	Destination string    `json:"destination"`
+	FeedID      int64     `json:"feed_id"`
+	FeedTitle   string    `json:"feed_title"`
+	EntryID     int64     `json:"entry_id"`
+	EntryTitle  string    `json:"entry_title"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error"`
+	CreatedAt   time.Time `json:"created_at"`
+	FailedAt    time.Time `json:"failed_at"`
+}
+
+// deadLetterDetail is a dead letter with its payload and attempt history.
+type deadLetterDetail struct {
+	ID          uint64                     `json:"id"`
+	Destination string                     `json:"destination"`
+	Feed        *models.WebhookFeed        `json:"feed"`
+	Entry       *models.WebhookEntry       `json:"entry"`
+	LastError   string                     `json:"last_error"`
+	Attempts    []services.DeliveryAttempt `json:"attempts"`
+	CreatedAt   time.Time                  `json:"created_at"`
+	FailedAt    time.Time                  `json:"failed_at"`
+}
+
+// RequireToken rejects requests without the configured admin token. The whole
+// admin API answers 404 while no token is configured.
+func (h *AdminHandler) RequireToken(c *gin.Context) {
+	token := h.config.Admin.Token
+	if token == "" {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "admin API is disabled"})
+		return
+	}
+	given, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid admin token"})
+		return
+	}
+	c.Next()
+}
+
+// ListDeadLetters lists dead letters, optionally filtered by the destination
+// and feed_id query parameters.
+func (h *AdminHandler) ListDeadLetters(c *gin.Context) {
+	filter, err := parseDeadLetterFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	jobs, err := h.store.DeadLetters(filter)
+	if err != nil {
+		log.Printf("Failed to list dead letters: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list dead letters"})
+		return
+	}
+
+	items := make([]deadLetterSummary, 0, len(jobs))
+	for _, job := range jobs {
+		items = append(items, deadLetterSummary{
+			ID:          job.ID,
+			Destination: job.DestinationName,
+			FeedID:      job.Feed.ID,
+			FeedTitle:   job.Feed.Title,
+			EntryID:     job.Entry.ID,
+			EntryTitle:  job.Entry.Title,
+			Attempts:    len(job.Attempts),
+			LastError:   job.LastError,
+			CreatedAt:   job.CreatedAt,
+			FailedAt:    job.FinishedAt,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"dead_letters": items, "total": len(items)})
+}
+
+// GetDeadLetter returns one dead letter with its payload and attempts.
+func (h *AdminHandler) GetDeadLetter(c *gin.Context) {
+	id, ok := parseDeadLetterID(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.store.DeadLetter(id)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deadLetterDetail{
+		ID:          job.ID,
+		Destination: job.DestinationName,
+		Feed:        job.Feed,
+		Entry:       job.Entry,
+		LastError:   job.LastError,
+		Attempts:    job.Attempts,
+		CreatedAt:   job.CreatedAt,
+		FailedAt:    job.FinishedAt,
+	})
+}
+
+// ReplayDeadLetter queues one dead letter for delivery again.
+func (h *AdminHandler) ReplayDeadLetter(c *gin.Context) {
+	id, ok := parseDeadLetterID(c)
+	if !ok {
+		return
+	}
+
+	if _, err := h.replayer.Replay([]uint64{id}); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	log.Printf("Replaying dead letter %d", id)
+	c.JSON(http.StatusAccepted, gin.H{"replayed": 1})
+}
+
+// ReplayDeadLetters queues the dead letters of a destination and/or feed for
+// delivery again. At least one filter is required so that a bare request
+// cannot flood every bot at once. Only as many as fit in the delivery queues
+// are replayed; the response tells how many remain for a later request.
+func (h *AdminHandler) ReplayDeadLetters(c *gin.Context) {
+	filter, err := parseDeadLetterFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter == (services.DeadLetterFilter{}) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "destination or feed_id is required"})
+		return
+	}
+
+	ids, err := h.matchingIDs(filter)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	replayed := 0
+	if len(ids) > 0 {
+		replayed, err = h.replayer.Replay(ids)
+		if err != nil {
+			h.respondError(c, err)
+			return
+		}
+	}
+
+	log.Printf("Replaying %d of %d dead letters (destination: %q, feed: %d)", replayed, len(ids), filter.Destination, filter.FeedID)
+	c.JSON(http.StatusAccepted, gin.H{"replayed": replayed, "remaining": len(ids) - replayed})
+}
+
+// DeleteDeadLetter purges one dead letter.
+func (h *AdminHandler) DeleteDeadLetter(c *gin.Context) {
+	id, ok := parseDeadLetterID(c)
+	if !ok {
+		return
+	}
+
+	purged, err := h.store.PurgeDeadLetters([]uint64{id})
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	if purged == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrDeadLetterNotFound.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"purged": purged})
+}
+
+// PurgeDeadLetters purges the dead letters matching the destination and
+// feed_id query parameters, or all of them when neither is given.
+func (h *AdminHandler) PurgeDeadLetters(c *gin.Context) {
+	filter, err := parseDeadLetterFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ids, err := h.matchingIDs(filter)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	purged, err := h.store.PurgeDeadLetters(ids)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	log.Printf("Purged %d dead letters (destination: %q, feed: %d)", purged, filter.Destination, filter.FeedID)
+	c.JSON(http.StatusOK, gin.H{"purged": purged})
+}
+
+func (h *AdminHandler) matchingIDs(filter services.DeadLetterFilter) ([]uint64, error) {
+	jobs, err := h.store.DeadLetters(filter)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint64, 0, len(jobs))
+	for _, job := range jobs {
+		ids = append(ids, job.ID)
+	}
+	return ids, nil
+}
+
+func (h *AdminHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrDeadLetterNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUnknownDestination):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrQueueFull), errors.Is(err, services.ErrDispatcherStopped):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Admin request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+	}
+}
+
+func parseDeadLetterID(c *gin.Context) (uint64, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return 0, false
+	}
+	return id, true
+}
+
+func parseDeadLetterFilter(c *gin.Context) (services.DeadLetterFilter, error) {
+	filter := services.DeadLetterFilter{Destination: c.Query("destination")}
+	if v := c.Query("feed_id"); v != "" {
+		feedID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return filter, errors.New("invalid feed_id")
+		}
+		filter.FeedID = feedID
+	}
+	return filter, nil
+}
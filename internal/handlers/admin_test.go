@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/models"
+	"miniflux-feishu/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testAdminToken = "admin-token"
+
+// newTestAdminRouter serves the admin API over an outbox holding one dead
+// letter per entry ID
+func newTestAdminRouter(t *testing.T, sender services.EntrySender, entryIDs ...int64) (*gin.Engine, *services.Outbox, *services.Dispatcher) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		Admin:        config.AdminConfig{Token: testAdminToken},
+		Delivery:     config.DeliveryConfig{Workers: 1, QueueSize: 10},
+		Outbox:       config.OutboxConfig{Path: filepath.Join(t.TempDir(), "outbox.db")},
+		Destinations: map[string]*config.Destination{"team": {Name: "team"}, "ops": {Name: "ops"}},
+	}
+	outbox, err := services.OpenOutbox(cfg)
+	if err != nil {
+		t.Fatalf("Failed to open outbox: %v", err)
+	}
+	t.Cleanup(func() {
+		outbox.Close() //nolint:errcheck
+	})
+
+	for _, id := range entryIDs {
+		dest := cfg.Destinations["team"]
+		if id%2 == 0 {
+			dest = cfg.Destinations["ops"]
+		}
+		job := &services.DeliveryJob{
+			Destination: dest,
+			Feed:        &models.WebhookFeed{ID: 8, Title: "Example Feed"},
+			Entry:       &models.WebhookEntry{ID: id, Title: "Entry"},
+		}
+		if err := outbox.Add([]*services.DeliveryJob{job}); err != nil {
+			t.Fatalf("Failed to add job: %v", err)
+		}
+		if err := outbox.Bury(job, "feishu API returned status 500", []services.DeliveryAttempt{{Kind: "server_error", StatusCode: 500}}); err != nil {
+			t.Fatalf("Failed to bury job: %v", err)
+		}
+	}
+
+	dispatcher, err := services.NewDispatcher(sender, outbox, cfg)
+	if err != nil {
+		t.Fatalf("Failed to create dispatcher: %v", err)
+	}
+	handler := NewAdminHandler(outbox, dispatcher, cfg)
+
+	router := gin.New()
+	admin := router.Group("/admin", handler.RequireToken)
+	admin.GET("/dead-letters", handler.ListDeadLetters)
+	admin.DELETE("/dead-letters", handler.PurgeDeadLetters)
+	admin.POST("/dead-letters/replay", handler.ReplayDeadLetters)
+	admin.GET("/dead-letters/:id", handler.GetDeadLetter)
+	admin.DELETE("/dead-letters/:id", handler.DeleteDeadLetter)
+	admin.POST("/dead-letters/:id/replay", handler.ReplayDeadLetter)
+	return router, outbox, dispatcher
+}
+
+func adminRequest(router *gin.Engine, method, target, token string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminHandler_RequireToken(t *testing.T) {
+	router, _, _ := newTestAdminRouter(t, &MockFeishuService{})
+
+	if w := adminRequest(router, "GET", "/admin/dead-letters", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without token, got %d", w.Code)
+	}
+	if w := adminRequest(router, "GET", "/admin/dead-letters", "wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 with wrong token, got %d", w.Code)
+	}
+
+	disabled := NewAdminHandler(nil, nil, &config.Config{})
+	r := gin.New()
+	r.GET("/admin/dead-letters", disabled.RequireToken, disabled.ListDeadLetters)
+	if w := adminRequest(r, "GET", "/admin/dead-letters", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 when no token is configured, got %d", w.Code)
+	}
+}
+
+func TestAdminHandler_ListAndInspect(t *testing.T) {
+	router, _, _ := newTestAdminRouter(t, &MockFeishuService{}, 1, 2, 3)
+
+	w := adminRequest(router, "GET", "/admin/dead-letters?destination=team", testAdminToken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var list struct {
+		DeadLetters []deadLetterSummary `json:"dead_letters"`
+		Total       int                 `json:"total"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if list.Total != 2 || list.DeadLetters[0].EntryID != 1 || list.DeadLetters[1].EntryID != 3 {
+		t.Errorf("Expected entries 1 and 3 for destination team, got %+v", list.DeadLetters)
+	}
+	if list.DeadLetters[0].Attempts != 1 || list.DeadLetters[0].FeedTitle != "Example Feed" {
+		t.Errorf("Unexpected summary %+v", list.DeadLetters[0])
+	}
+
+	w = adminRequest(router, "GET", "/admin/dead-letters/2", testAdminToken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var detail deadLetterDetail
+	if err := json.Unmarshal(w.Body.Bytes(), &detail); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if detail.Entry.ID != 2 || detail.Destination != "ops" || detail.LastError != "feishu API returned status 500" {
+		t.Errorf("Unexpected detail %+v", detail)
+	}
+	if len(detail.Attempts) != 1 || detail.Attempts[0].StatusCode != 500 {
+		t.Errorf("Expected attempt history, got %+v", detail.Attempts)
+	}
+
+	if w := adminRequest(router, "GET", "/admin/dead-letters/42", testAdminToken); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown id, got %d", w.Code)
+	}
+	if w := adminRequest(router, "GET", "/admin/dead-letters/abc", testAdminToken); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid id, got %d", w.Code)
+	}
+}
+
+func TestAdminHandler_Replay(t *testing.T) {
+	mockService := &MockFeishuService{}
+	router, outbox, dispatcher := newTestAdminRouter(t, mockService, 1, 2, 3)
+	dispatcher.Start()
+
+	if w := adminRequest(router, "POST", "/admin/dead-letters/2/replay", testAdminToken); w.Code != http.StatusAccepted {
+		t.Errorf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := adminRequest(router, "POST", "/admin/dead-letters/2/replay", testAdminToken); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a replayed dead letter, got %d", w.Code)
+	}
+	if w := adminRequest(router, "POST", "/admin/dead-letters/replay", testAdminToken); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for bulk replay without filter, got %d", w.Code)
+	}
+
+	w := adminRequest(router, "POST", "/admin/dead-letters/replay?destination=team&feed_id=8", testAdminToken)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var response map[string]int
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response["replayed"] != 2 || response["remaining"] != 0 {
+		t.Errorf("Expected 2 replayed and 0 remaining dead letters, got %v", response)
+	}
+
+	if err := dispatcher.Stop(context.Background()); err != nil {
+		t.Fatalf("Failed to stop dispatcher: %v", err)
+	}
+	if mockService.callCount != 3 {
+		t.Errorf("Expected 3 deliveries, got %d", mockService.callCount)
+	}
+	dead, err := outbox.DeadLetters(services.DeadLetterFilter{})
+	if err != nil {
+		t.Fatalf("Failed to list dead letters: %v", err)
+	}
+	if len(dead) != 0 {
+		t.Errorf("Expected no dead letters left, got %d", len(dead))
+	}
+}
+
+func TestAdminHandler_Purge(t *testing.T) {
+	router, outbox, _ := newTestAdminRouter(t, &MockFeishuService{}, 1, 2, 3, 4)
+
+	if w := adminRequest(router, "DELETE", "/admin/dead-letters/1", testAdminToken); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+	if w := adminRequest(router, "DELETE", "/admin/dead-letters/1", testAdminToken); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a purged dead letter, got %d", w.Code)
+	}
+
+	w := adminRequest(router, "DELETE", "/admin/dead-letters?destination=ops", testAdminToken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var response map[string]int
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response["purged"] != 2 {
+		t.Errorf("Expected 2 purged dead letters, got %d", response["purged"])
+	}
+
+	dead, err := outbox.DeadLetters(services.DeadLetterFilter{})
+	if err != nil {
+		t.Fatalf("Failed to list dead letters: %v", err)
+	}
+	if len(dead) != 1 || dead[0].Entry.ID != 3 {
+		t.Errorf("Expected only entry 3 to be left, got %v", dead)
+	}
+}
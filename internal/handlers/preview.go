@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/expression"
+	"miniflux-feishu/internal/models"
+	"miniflux-feishu/internal/services"
+	"miniflux-feishu/internal/templates"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MessageRenderer builds the Feishu message of an entry
+type MessageRenderer interface {
+	RenderMessage(entry *models.WebhookEntry, feed *models.WebhookFeed, dest *config.Destination) (services.FeishuMessage, error)
+}
+
+// PreviewHandler renders messages without sending them, to try out formats
+// and templates.
+type PreviewHandler struct {
+	renderer MessageRenderer
+	config   *config.Config
+}
+
+func NewPreviewHandler(renderer MessageRenderer, cfg *config.Config) *PreviewHandler {
+	return &PreviewHandler{
+		renderer: renderer,
+		config:   cfg,
+	}
+}
+
+// previewRequest selects what to render. Format and Template override the
+// settings of Destination, and a sample entry is used when Entry or Feed is
+// missing.
+type previewRequest struct {
+	Destination string               `json:"destination"`
+	Format      string               `json:"format"`
+	Template    string               `json:"template"`
+	Feed        *models.WebhookFeed  `json:"feed"`
+	Entry       *models.WebhookEntry `json:"entry"`
+}
+
+// PreviewMessage responds with the message body that would be sent to Feishu.
+func (h *PreviewHandler) PreviewMessage(c *gin.Context) {
+	var req previewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+		return
+	}
+
+	dest := &config.Destination{Name: "preview"}
+	if req.Destination != "" {
+		configured, ok := h.config.Destinations[req.Destination]
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("unknown destination %q", req.Destination)})
+			return
+		}
+		copied := *configured
+		dest = &copied
+	}
+	if req.Format != "" {
+		dest.Format = req.Format
+	}
+	if req.Template != "" {
+		dest.Template = req.Template
+	}
+	switch dest.Format {
+	case "", config.FormatText, config.FormatPost, config.FormatCard:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown format %q", dest.Format)})
+		return
+	}
+
+	sampleEntry, sampleFeed := templates.Sample()
+	if req.Entry == nil {
+		req.Entry = sampleEntry
+	}
+	if req.Feed == nil {
+		req.Feed = sampleFeed
+	}
+
+	message, err := h.renderer.RenderMessage(req.Entry, req.Feed, dest)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	body, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Failed to encode preview: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encode message"})
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// filterPreviewRequest holds an expression, or the destination whose filter
+// is tried, and a sample payload. The sample entry is used when Entries or
+// Feed is missing.
+type filterPreviewRequest struct {
+	Destination string                 `json:"destination"`
+	Expression  string                 `json:"expression"`
+	Feed        *models.WebhookFeed    `json:"feed"`
+	Entries     []*models.WebhookEntry `json:"entries"`
+}
+
+type filterResult struct {
+	EntryID int64  `json:"entry_id"`
+	Title   string `json:"title"`
+	Matched bool   `json:"matched"`
+	Error   string `json:"error,omitempty"`
+}
+
+// PreviewFilter evaluates a filter expression, or the filter of a destination,
+// against sample entries without sending anything.
+func (h *PreviewHandler) PreviewFilter(c *gin.Context) {
+	var req filterPreviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+		return
+	}
+
+	var program *expression.Program
+	var filter config.FilterConfig
+	switch {
+	case req.Expression != "":
+		var err error
+		program, err = expression.Compile(req.Expression)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+	case req.Destination != "":
+		dest, ok := h.config.Destinations[req.Destination]
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("unknown destination %q", req.Destination)})
+			return
+		}
+		filter = dest.Filter
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expression or destination is required"})
+		return
+	}
+
+	sampleEntry, sampleFeed := templates.Sample()
+	if len(req.Entries) == 0 {
+		req.Entries = []*models.WebhookEntry{sampleEntry}
+	}
+	if req.Feed == nil {
+		req.Feed = sampleFeed
+	}
+
+	results := make([]filterResult, 0, len(req.Entries))
+	matched := 0
+	for _, entry := range req.Entries {
+		if entry == nil {
+			continue
+		}
+		result := filterResult{EntryID: entry.ID, Title: entry.Title}
+		var err error
+		if program != nil {
+			result.Matched, err = program.Match(entry, req.Feed)
+		} else {
+			result.Matched, err = services.EvaluateFilter(filter, entry, req.Feed)
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		if result.Matched {
+			matched++
+		}
+		results = append(results, result)
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results, "matched": matched})
+}
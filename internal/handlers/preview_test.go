@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPreviewHandler_PreviewMessage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		Destinations: map[string]*config.Destination{
+			"team": {Name: "team", Format: config.FormatCard},
+			"ops":  {Name: "ops", Template: `{"title": {{json .Entry.Title}}, "content": "custom", "url": ""}`},
+		},
+	}
+	handler := NewPreviewHandler(services.NewFeishuService(cfg), cfg)
+	router := gin.New()
+	router.POST("/admin/preview", handler.PreviewMessage)
+
+	tests := []struct {
+		name            string
+		body            string
+		expectedStatus  int
+		expectedMsgType string
+		expectedTitle   string
+	}{
+		{
+			name:            "configured format",
+			body:            `{"destination": "team"}`,
+			expectedStatus:  http.StatusOK,
+			expectedMsgType: "interactive",
+		},
+		{
+			name:            "configured template",
+			body:            `{"destination": "ops", "entry": {"id": 1, "title": "Custom entry"}}`,
+			expectedStatus:  http.StatusOK,
+			expectedMsgType: "text",
+			expectedTitle:   "Custom entry",
+		},
+		{
+			name:            "template override",
+			body:            `{"destination": "team", "format": "text", "template": "{\"title\": {{json .Feed.Title}}}"}`,
+			expectedStatus:  http.StatusOK,
+			expectedMsgType: "text",
+			expectedTitle:   "Example website",
+		},
+		{
+			name:            "default text format",
+			body:            `{}`,
+			expectedStatus:  http.StatusOK,
+			expectedMsgType: "text",
+			expectedTitle:   "[Example website] - Example",
+		},
+		{
+			name:           "unknown destination",
+			body:           `{"destination": "nope"}`,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "unknown format",
+			body:           `{"format": "html"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "broken template",
+			body:           `{"template": "{{.Entry.Nope}}"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/admin/preview", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var message struct {
+				MsgType string `json:"msg_type"`
+				Content struct {
+					Title string `json:"title"`
+				} `json:"content"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &message); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			if message.MsgType != tt.expectedMsgType {
+				t.Errorf("Expected msg_type %s, got %s", tt.expectedMsgType, message.MsgType)
+			}
+			if message.Content.Title != tt.expectedTitle {
+				t.Errorf("Expected title %q, got %q", tt.expectedTitle, message.Content.Title)
+			}
+		})
+	}
+}
+
+func TestPreviewHandler_PreviewFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	filter := config.FilterConfig{Expression: `reading_time >= 5`}
+	if err := filter.Compile(); err != nil {
+		t.Fatalf("Failed to compile filter: %v", err)
+	}
+	// Entries without tags make this expression fail at run time
+	failing := config.FilterConfig{Expression: `tags[0] == 'go'`}
+	if err := failing.Compile(); err != nil {
+		t.Fatalf("Failed to compile filter: %v", err)
+	}
+	cfg := &config.Config{
+		Destinations: map[string]*config.Destination{
+			"research": {Name: "research", Filter: filter},
+			"tagged":   {Name: "tagged", Filter: failing},
+		},
+	}
+	handler := NewPreviewHandler(services.NewFeishuService(cfg), cfg)
+	router := gin.New()
+	router.POST("/admin/preview/filter", handler.PreviewFilter)
+
+	entries := `"feed": {"id": 1, "title": "Papers", "category": {"id": 2, "title": "Research"}},
+		"entries": [
+			{"id": 1, "title": "Long read", "reading_time": 12},
+			{"id": 2, "title": "Weekly digest", "reading_time": 8},
+			{"id": 3, "title": "Short note", "reading_time": 1}
+		]`
+
+	tests := []struct {
+		name            string
+		body            string
+		expectedStatus  int
+		expectedMatches []bool
+		expectedError   string
+	}{
+		{
+			name:            "expression",
+			body:            `{"expression": "reading_time > 5 && feed.category.title == 'Research' && !(title matches '(?i)weekly')", ` + entries + `}`,
+			expectedStatus:  http.StatusOK,
+			expectedMatches: []bool{true, false, false},
+		},
+		{
+			name:            "destination filter",
+			body:            `{"destination": "research", ` + entries + `}`,
+			expectedStatus:  http.StatusOK,
+			expectedMatches: []bool{true, true, false},
+		},
+		{
+			name:            "destination filter error",
+			body:            `{"destination": "tagged", ` + entries + `}`,
+			expectedStatus:  http.StatusOK,
+			expectedMatches: []bool{false, false, false},
+			expectedError:   "out of range",
+		},
+		{
+			name:            "sample entry",
+			body:            `{"expression": "title == 'Example'"}`,
+			expectedStatus:  http.StatusOK,
+			expectedMatches: []bool{true},
+		},
+		{
+			name:           "type error",
+			body:           `{"expression": "title > 5"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedError:  "invalid operation",
+		},
+		{
+			name:           "unknown field",
+			body:           `{"expression": "readingtime > 5"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedError:  "unknown name readingtime",
+		},
+		{
+			name:           "unknown destination",
+			body:           `{"destination": "nope"}`,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "nothing to evaluate",
+			body:           `{}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/admin/preview/filter", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedError != "" && !strings.Contains(w.Body.String(), tt.expectedError) {
+				t.Errorf("Expected error containing %q, got %s", tt.expectedError, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Results []struct {
+					Matched bool `json:"matched"`
+				} `json:"results"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			var matches []bool
+			for _, r := range response.Results {
+				matches = append(matches, r.Matched)
+			}
+			if !slices.Equal(matches, tt.expectedMatches) {
+				t.Errorf("Expected matches %v, got %v", tt.expectedMatches, matches)
+			}
+		})
+	}
+}
@@ -1,30 +1,87 @@
 package handlers
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 
+	"miniflux-feishu/internal/config"
 	"miniflux-feishu/internal/models"
+	"miniflux-feishu/internal/services"
 
 	"github.com/gin-gonic/gin"
 )
 
+// maxWebhookBodySize bounds the webhook body read before the signature is
+// checked. Miniflux batches with full article content stay well below it.
+const maxWebhookBodySize = 8 << 20
+
+var (
+	errMissingSignature = errors.New("missing signature")
+	errInvalidSignature = errors.New("invalid signature")
+)
+
 // FeishuServiceInterface defines the interface for Feishu service
 type FeishuServiceInterface interface {
-	SendEntryToFeishu(entry *models.WebhookEntry, feed *models.WebhookFeed, webhookURL string) error
+	ValidateWebhookURL(webhookURL string) error
+}
+
+// EntryRouter picks the destinations of entries when the request names none
+type EntryRouter interface {
+	Route(entry *models.WebhookEntry, feed *models.WebhookFeed) []*config.Destination
+}
+
+// DeliveryQueue accepts entries for asynchronous delivery
+type DeliveryQueue interface {
+	Enqueue(jobs []*services.DeliveryJob) error
 }
 
 type WebhookHandler struct {
 	feishuService FeishuServiceInterface
+	queue         DeliveryQueue
+	router        EntryRouter
+	config        *config.Config
 }
 
-func NewWebhookHandler(feishuService FeishuServiceInterface) *WebhookHandler {
+func NewWebhookHandler(feishuService FeishuServiceInterface, queue DeliveryQueue, router EntryRouter, cfg *config.Config) *WebhookHandler {
 	return &WebhookHandler{
 		feishuService: feishuService,
+		queue:         queue,
+		router:        router,
+		config:        cfg,
 	}
 }
 
 func (h *WebhookHandler) HandleMinifluxWebhook(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBodySize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			log.Printf("Rejected webhook from %s: body exceeds %d bytes", c.ClientIP(), tooLarge.Limit)
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Payload too large"})
+			return
+		}
+		log.Printf("Failed to read webhook body: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+		return
+	}
+
+	source, err := h.verifySignature(body, c.GetHeader("X-Miniflux-Signature"))
+	if err != nil {
+		log.Printf("Rejected webhook from %s: %v", c.ClientIP(), err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
 	eventType := c.GetHeader("X-Miniflux-Event-Type")
 	if eventType != "new_entries" {
 		log.Printf("Ignoring event type: %s", eventType)
@@ -32,31 +89,254 @@ func (h *WebhookHandler) HandleMinifluxWebhook(c *gin.Context) {
 		return
 	}
 
-	// 获取飞书 webhook URL 参数
-	webhookURL := c.Query("webhook_url")
-	if webhookURL == "" {
-		log.Printf("Missing webhook_url parameter")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "webhook_url parameter is required"})
+	dests, status, err := h.resolveDestinations(c)
+	if err != nil {
+		log.Printf("Failed to resolve destination: %v", err)
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Routed destinations are checked once they are picked. A rejected
+	// destination does not keep the entries from the others.
+	results := make(map[string]*destinationResult)
+	if dests != nil {
+		accepted := 0
+		for _, dest := range dests {
+			if h.checkDestination(results, dest) {
+				accepted++
+			}
+		}
+		if accepted == 0 {
+			c.JSON(http.StatusForbidden, gin.H{"error": results[dests[0].Name].Error})
+			return
+		}
+	}
+
 	var webhookEvent models.WebhookNewEntriesEvent
-	if err := c.ShouldBindJSON(&webhookEvent); err != nil {
+	if err := json.Unmarshal(body, &webhookEvent); err != nil {
 		log.Printf("Failed to parse webhook payload: %v", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
 		return
 	}
+	if err := validateEvent(&webhookEvent); err != nil {
+		log.Printf("Invalid webhook payload: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+		return
+	}
 
-	log.Printf("Received %d new entries from feed: %s", len(webhookEvent.Entries), webhookEvent.Feed.Title)
-	log.Printf("Using webhook URL: %s", webhookURL)
+	log.Printf("Received %d new entries from feed: %s (source: %s)", len(webhookEvent.Entries), webhookEvent.Feed.Title, source)
 
+	jobs := make([]*services.DeliveryJob, 0, len(webhookEvent.Entries))
+	// filtered counts the deliveries dropped by destination filters
+	filtered := 0
 	for _, entry := range webhookEvent.Entries {
-		if err := h.feishuService.SendEntryToFeishu(entry, webhookEvent.Feed, webhookURL); err != nil {
-			log.Printf("Failed to send entry %d to Feishu: %v", entry.ID, err)
-		} else {
-			log.Printf("Successfully sent entry %d to Feishu", entry.ID)
+		for _, dest := range h.destinationsFor(dests, entry, webhookEvent.Feed) {
+			if !h.checkDestination(results, dest) {
+				continue
+			}
+			result := results[dest.Name]
+			if !services.MatchesFilter(dest.Filter, entry, webhookEvent.Feed) {
+				result.Filtered++
+				filtered++
+				continue
+			}
+			result.Queued++
+			jobs = append(jobs, &services.DeliveryJob{
+				Destination: dest,
+				Feed:        webhookEvent.Feed,
+				Entry:       entry,
+			})
+		}
+	}
+
+	target := "routing rules"
+	if dests != nil {
+		target = "destination: " + destinationNames(dests)
+	}
+	if len(jobs) == 0 {
+		for _, result := range results {
+			if result.Error != "" {
+				c.JSON(http.StatusForbidden, gin.H{"error": result.Error, "destinations": results})
+				return
+			}
+		}
+		log.Printf("All %d entries were filtered out for %s (%d by filters)", len(webhookEvent.Entries), target, filtered)
+		message := "No entries matched the destination filter"
+		if dests == nil {
+			message = "No entries matched a routing rule"
+		}
+		c.JSON(http.StatusOK, gin.H{"message": message, "queued": 0, "filtered": filtered, "destinations": results})
+		return
+	}
+
+	queued := len(jobs)
+	if err := h.queue.Enqueue(jobs); err != nil {
+		log.Printf("Failed to enqueue %d entries for %s: %v", len(jobs), target, err)
+		// A full queue only rejects the jobs of its own destination
+		var full *services.QueueFullError
+		if errors.As(err, &full) {
+			for _, name := range full.Destinations {
+				result := results[name]
+				queued -= result.Queued
+				result.Queued = 0
+				result.Error = services.ErrQueueFull.Error()
+			}
+		}
+		if full != nil && queued > 0 {
+			log.Printf("Queued %d entries for %s, %d filtered", queued, target, filtered)
+			c.JSON(http.StatusAccepted, gin.H{"message": "Webhook accepted", "queued": queued, "filtered": filtered, "destinations": results})
+			return
+		}
+		retryAfter := int(h.config.Delivery.RetryAfter.Seconds())
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("Queued %d entries for %s, %d filtered", queued, target, filtered)
+	c.JSON(http.StatusAccepted, gin.H{"message": "Webhook accepted", "queued": queued, "filtered": filtered, "destinations": results})
+}
+
+// destinationResult is the part of a webhook that went to one destination.
+type destinationResult struct {
+	Queued   int    `json:"queued"`
+	Filtered int    `json:"filtered"`
+	Error    string `json:"error,omitempty"`
+}
+
+// checkDestination validates the webhook URL of a destination the first time
+// it is seen and records the outcome in results.
+func (h *WebhookHandler) checkDestination(results map[string]*destinationResult, dest *config.Destination) bool {
+	if result, ok := results[dest.Name]; ok {
+		return result.Error == ""
+	}
+	result := &destinationResult{}
+	results[dest.Name] = result
+	if err := h.feishuService.ValidateWebhookURL(dest.WebhookURL); err != nil {
+		log.Printf("Rejected destination %s: %v", dest.Name, err)
+		result.Error = err.Error()
+		return false
+	}
+	return true
+}
+
+func destinationNames(dests []*config.Destination) string {
+	names := make([]string, 0, len(dests))
+	for _, dest := range dests {
+		names = append(names, dest.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// validateEvent rejects payloads that would fail later in the delivery workers.
+func validateEvent(event *models.WebhookNewEntriesEvent) error {
+	if event.Feed == nil {
+		return errors.New("missing feed")
+	}
+	for i, entry := range event.Entries {
+		if entry == nil {
+			return fmt.Errorf("entry %d is null", i)
+		}
+	}
+	return nil
+}
+
+// destinationsFor returns the destinations of the request, or the routed ones
+// when the request did not name any.
+func (h *WebhookHandler) destinationsFor(dests []*config.Destination, entry *models.WebhookEntry, feed *models.WebhookFeed) []*config.Destination {
+	if dests != nil {
+		return dests
+	}
+	return h.router.Route(entry, feed)
+}
+
+// resolveDestinations picks the destinations named in the URL path, separated
+// by commas, falling back to the webhook_url query parameter when legacy mode
+// is enabled. No destinations means the entries are routed by the routing
+// rules. The returned status code is meant for the client when resolution
+// fails.
+func (h *WebhookHandler) resolveDestinations(c *gin.Context) ([]*config.Destination, int, error) {
+	if param := c.Param("destination"); param != "" {
+		var dests []*config.Destination
+		for _, name := range strings.Split(param, ",") {
+			name = strings.TrimSpace(name)
+			dest, ok := h.config.Destinations[name]
+			if !ok {
+				return nil, http.StatusNotFound, fmt.Errorf("unknown destination %q", name)
+			}
+			if !slices.Contains(dests, dest) {
+				dests = append(dests, dest)
+			}
+		}
+		return dests, http.StatusOK, nil
+	}
+
+	// 获取飞书 webhook URL 参数
+	webhookURL := c.Query("webhook_url")
+	if webhookURL == "" && h.config.Routing.Enabled() {
+		return nil, http.StatusOK, nil
+	}
+	if !h.config.LegacyWebhookURL {
+		if webhookURL != "" {
+			return nil, http.StatusBadRequest, errors.New("webhook_url parameter is disabled, use /webhook/miniflux/:destination")
+		}
+		return nil, http.StatusBadRequest, errors.New("destination is required")
+	}
+	if webhookURL == "" {
+		return nil, http.StatusBadRequest, errors.New("webhook_url parameter is required")
+	}
+
+	// 已配置的 destination 会带上签名密钥
+	if dest := h.config.DestinationByURL(webhookURL); dest != nil {
+		return []*config.Destination{dest}, http.StatusOK, nil
+	}
+	return []*config.Destination{{
+		Name:       "legacy",
+		WebhookURL: webhookURL,
+		Legacy:     true,
+		Retry:      h.config.Delivery.Retry,
+		RateLimit:  h.config.Delivery.RateLimit,
+		Location:   h.config.Location,
+	}}, http.StatusOK, nil
+}
+
+// verifySignature checks the X-Miniflux-Signature header against the secrets
+// of all configured sources and returns the name of the matching source.
+// Unsigned requests are only accepted when strict mode is off.
+func (h *WebhookHandler) verifySignature(body []byte, signature string) (string, error) {
+	sources := h.config.Miniflux.Sources
+	if signature == "" {
+		if h.config.Miniflux.RequireSignature {
+			return "", errMissingSignature
 		}
+		if len(sources) > 0 {
+			log.Printf("Accepting unsigned webhook request, enable miniflux.require_signature to reject it")
+		}
+		return "unsigned", nil
+	}
+	if len(sources) == 0 {
+		return "unverified", nil
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Webhook processed successfully"})
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return "", errInvalidSignature
+	}
+
+	matched := -1
+	for i, source := range sources {
+		mac := hmac.New(sha256.New, []byte(source.Secret))
+		mac.Write(body)
+		// Keep comparing after a match so timing does not reveal which source matched.
+		if hmac.Equal(mac.Sum(nil), expected) && matched < 0 {
+			matched = i
+		}
+	}
+	if matched < 0 {
+		return "", errInvalidSignature
+	}
+	return sources[matched].Name, nil
 }
@@ -2,12 +2,23 @@ package handlers
 
 import (
 	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"miniflux-feishu/internal/config"
 	"miniflux-feishu/internal/models"
 	"miniflux-feishu/internal/services"
 
@@ -16,31 +27,80 @@ import (
 
 // MockFeishuService is a mock implementation of FeishuServiceInterface for testing
 type MockFeishuService struct {
-	sendEntryToFeishuFunc func(entry *models.WebhookEntry, feed *models.WebhookFeed, webhookURL string) error
+	mu                    sync.Mutex
+	sendEntryToFeishuFunc func(entry *models.WebhookEntry, feed *models.WebhookFeed, dest *config.Destination) error
+	validateFunc          func(webhookURL string) error
 	callCount             int
 	lastEntry             *models.WebhookEntry
 	lastFeed              *models.WebhookFeed
+	lastDestination       *config.Destination
 	lastWebhookURL        string
 }
 
-func (m *MockFeishuService) SendEntryToFeishu(entry *models.WebhookEntry, feed *models.WebhookFeed, webhookURL string) error {
+func (m *MockFeishuService) SendEntryToFeishu(ctx context.Context, entry *models.WebhookEntry, feed *models.WebhookFeed, dest *config.Destination) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.callCount++
 	m.lastEntry = entry
 	m.lastFeed = feed
-	m.lastWebhookURL = webhookURL
+	m.lastDestination = dest
+	m.lastWebhookURL = dest.WebhookURL
 
 	if m.sendEntryToFeishuFunc != nil {
-		return m.sendEntryToFeishuFunc(entry, feed, webhookURL)
+		return m.sendEntryToFeishuFunc(entry, feed, dest)
+	}
+	return nil
+}
+
+func (m *MockFeishuService) ValidateWebhookURL(webhookURL string) error {
+	if m.validateFunc != nil {
+		return m.validateFunc(webhookURL)
 	}
 	return nil
 }
 
+// newTestHandler wires the handler to a single-worker dispatcher. The returned
+// function waits until all queued entries have been delivered.
+func newTestHandler(t *testing.T, service interface {
+	FeishuServiceInterface
+	services.EntrySender
+}, cfg *config.Config) (*WebhookHandler, func()) {
+	t.Helper()
+	if cfg.Delivery.Workers == 0 {
+		cfg.Delivery.Workers = 1
+	}
+	if cfg.Delivery.QueueSize == 0 {
+		cfg.Delivery.QueueSize = 100
+	}
+	cfg.Outbox.Path = filepath.Join(t.TempDir(), "outbox.db")
+	outbox, err := services.OpenOutbox(cfg)
+	if err != nil {
+		t.Fatalf("Failed to open outbox: %v", err)
+	}
+	t.Cleanup(func() {
+		outbox.Close() //nolint:errcheck
+	})
+	dispatcher, err := services.NewDispatcher(service, outbox, cfg)
+	if err != nil {
+		t.Fatalf("Failed to create dispatcher: %v", err)
+	}
+	dispatcher.Start()
+	router, err := services.NewEntryRouter(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+	return NewWebhookHandler(service, dispatcher, router, cfg), func() {
+		dispatcher.Stop(context.Background()) //nolint:errcheck
+	}
+}
+
 func TestWebhookHandler_HandleMinifluxWebhook_Success(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	// Create mock service
 	mockService := &MockFeishuService{}
-	handler := NewWebhookHandler(mockService)
+	handler, wait := newTestHandler(t, mockService, &config.Config{LegacyWebhookURL: true})
 
 	// Create test payload
 	payload := `{
@@ -98,10 +158,11 @@ func TestWebhookHandler_HandleMinifluxWebhook_Success(t *testing.T) {
 
 	// Execute request
 	router.ServeHTTP(w, req)
+	wait()
 
 	// Verify response
-	if w.Code != http.StatusOK {
-		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	if w.Code != http.StatusAccepted {
+		t.Errorf("Expected status code %d, got %d", http.StatusAccepted, w.Code)
 	}
 
 	// Verify response body
@@ -111,7 +172,7 @@ func TestWebhookHandler_HandleMinifluxWebhook_Success(t *testing.T) {
 		t.Fatalf("Failed to unmarshal response: %v", err)
 	}
 
-	if response["message"] != "Webhook processed successfully" {
+	if response["message"] != "Webhook accepted" {
 		t.Errorf("Expected success message, got %v", response["message"])
 	}
 
@@ -146,7 +207,7 @@ func TestWebhookHandler_HandleMinifluxWebhook_WrongEventType(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	mockService := &MockFeishuService{}
-	handler := NewWebhookHandler(mockService)
+	handler, wait := newTestHandler(t, mockService, &config.Config{LegacyWebhookURL: true})
 
 	payload := `{"event_type": "other_event"}`
 	req := httptest.NewRequest("POST", "/webhook?webhook_url=https://hooks.example.com/webhook", bytes.NewBufferString(payload))
@@ -157,6 +218,7 @@ func TestWebhookHandler_HandleMinifluxWebhook_WrongEventType(t *testing.T) {
 	router := gin.New()
 	router.POST("/webhook", handler.HandleMinifluxWebhook)
 	router.ServeHTTP(w, req)
+	wait()
 
 	// Should return OK but ignore the event
 	if w.Code != http.StatusOK {
@@ -183,7 +245,7 @@ func TestWebhookHandler_HandleMinifluxWebhook_MissingWebhookURL(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	mockService := &MockFeishuService{}
-	handler := NewWebhookHandler(mockService)
+	handler, wait := newTestHandler(t, mockService, &config.Config{LegacyWebhookURL: true})
 
 	payload := `{"event_type": "new_entries"}`
 	req := httptest.NewRequest("POST", "/webhook", bytes.NewBufferString(payload)) // No webhook_url parameter
@@ -194,6 +256,7 @@ func TestWebhookHandler_HandleMinifluxWebhook_MissingWebhookURL(t *testing.T) {
 	router := gin.New()
 	router.POST("/webhook", handler.HandleMinifluxWebhook)
 	router.ServeHTTP(w, req)
+	wait()
 
 	if w.Code != http.StatusBadRequest {
 		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
@@ -219,7 +282,7 @@ func TestWebhookHandler_HandleMinifluxWebhook_InvalidJSON(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	mockService := &MockFeishuService{}
-	handler := NewWebhookHandler(mockService)
+	handler, wait := newTestHandler(t, mockService, &config.Config{LegacyWebhookURL: true})
 
 	payload := `{invalid json`
 	req := httptest.NewRequest("POST", "/webhook?webhook_url=https://hooks.example.com/webhook", bytes.NewBufferString(payload))
@@ -230,6 +293,7 @@ func TestWebhookHandler_HandleMinifluxWebhook_InvalidJSON(t *testing.T) {
 	router := gin.New()
 	router.POST("/webhook", handler.HandleMinifluxWebhook)
 	router.ServeHTTP(w, req)
+	wait()
 
 	if w.Code != http.StatusBadRequest {
 		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
@@ -251,16 +315,41 @@ func TestWebhookHandler_HandleMinifluxWebhook_InvalidJSON(t *testing.T) {
 	}
 }
 
+func TestWebhookHandler_HandleMinifluxWebhook_PayloadTooLarge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := &MockFeishuService{}
+	handler, wait := newTestHandler(t, mockService, &config.Config{LegacyWebhookURL: true})
+
+	payload := `{"event_type": "new_entries", "padding": "` + strings.Repeat("x", maxWebhookBodySize) + `"}`
+	req := httptest.NewRequest("POST", "/webhook?webhook_url=https://hooks.example.com/webhook", bytes.NewBufferString(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Miniflux-Event-Type", "new_entries")
+
+	w := httptest.NewRecorder()
+	router := gin.New()
+	router.POST("/webhook", handler.HandleMinifluxWebhook)
+	router.ServeHTTP(w, req)
+	wait()
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status code %d, got %d", http.StatusRequestEntityTooLarge, w.Code)
+	}
+	if mockService.callCount != 0 {
+		t.Errorf("Expected FeishuService not to be called, but it was called %d times", mockService.callCount)
+	}
+}
+
 func TestWebhookHandler_HandleMinifluxWebhook_FeishuServiceError(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	// Create mock service that returns an error
 	mockService := &MockFeishuService{
-		sendEntryToFeishuFunc: func(entry *models.WebhookEntry, feed *models.WebhookFeed, webhookURL string) error {
+		sendEntryToFeishuFunc: func(entry *models.WebhookEntry, feed *models.WebhookFeed, dest *config.Destination) error {
 			return fmt.Errorf("feishu service error")
 		},
 	}
-	handler := NewWebhookHandler(mockService)
+	handler, wait := newTestHandler(t, mockService, &config.Config{LegacyWebhookURL: true})
 
 	payload := `{
 		"event_type": "new_entries",
@@ -303,10 +392,11 @@ func TestWebhookHandler_HandleMinifluxWebhook_FeishuServiceError(t *testing.T) {
 	router := gin.New()
 	router.POST("/webhook", handler.HandleMinifluxWebhook)
 	router.ServeHTTP(w, req)
+	wait()
 
-	// Should still return OK even if FeishuService fails (error is logged)
-	if w.Code != http.StatusOK {
-		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	// Should still accept the webhook even if FeishuService fails (error is logged)
+	if w.Code != http.StatusAccepted {
+		t.Errorf("Expected status code %d, got %d", http.StatusAccepted, w.Code)
 	}
 
 	var response map[string]interface{}
@@ -315,7 +405,7 @@ func TestWebhookHandler_HandleMinifluxWebhook_FeishuServiceError(t *testing.T) {
 		t.Fatalf("Failed to unmarshal response: %v", err)
 	}
 
-	if response["message"] != "Webhook processed successfully" {
+	if response["message"] != "Webhook accepted" {
 		t.Errorf("Expected success message, got %v", response["message"])
 	}
 
@@ -329,7 +419,7 @@ func TestWebhookHandler_HandleMinifluxWebhook_MultipleEntries(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	mockService := &MockFeishuService{}
-	handler := NewWebhookHandler(mockService)
+	handler, wait := newTestHandler(t, mockService, &config.Config{LegacyWebhookURL: true})
 
 	payload := `{
 		"event_type": "new_entries",
@@ -391,9 +481,10 @@ func TestWebhookHandler_HandleMinifluxWebhook_MultipleEntries(t *testing.T) {
 	router := gin.New()
 	router.POST("/webhook", handler.HandleMinifluxWebhook)
 	router.ServeHTTP(w, req)
+	wait()
 
-	if w.Code != http.StatusOK {
-		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	if w.Code != http.StatusAccepted {
+		t.Errorf("Expected status code %d, got %d", http.StatusAccepted, w.Code)
 	}
 
 	// FeishuService should be called once for each entry
@@ -406,7 +497,7 @@ func TestWebhookHandler_HandleMinifluxWebhook_MissingEventTypeHeader(t *testing.
 	gin.SetMode(gin.TestMode)
 
 	mockService := &MockFeishuService{}
-	handler := NewWebhookHandler(mockService)
+	handler, wait := newTestHandler(t, mockService, &config.Config{LegacyWebhookURL: true})
 
 	payload := `{"event_type": "new_entries"}`
 	req := httptest.NewRequest("POST", "/webhook?webhook_url=https://hooks.example.com/webhook", bytes.NewBufferString(payload))
@@ -417,6 +508,7 @@ func TestWebhookHandler_HandleMinifluxWebhook_MissingEventTypeHeader(t *testing.
 	router := gin.New()
 	router.POST("/webhook", handler.HandleMinifluxWebhook)
 	router.ServeHTTP(w, req)
+	wait()
 
 	// Should return OK but ignore the event (empty string != "new_entries")
 	if w.Code != http.StatusOK {
@@ -444,8 +536,17 @@ func TestWebhookHandler_IntegrationWithRealService(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	// Use real FeishuService
-	realService := services.NewFeishuService()
-	handler := NewWebhookHandler(realService)
+	cfg := &config.Config{
+		LegacyWebhookURL: true,
+		Security: config.SecurityConfig{
+			AllowedHosts:         []string{"127.0.0.1"},
+			AllowPrivateNetworks: true,
+		},
+		// Fail fast instead of retrying the simulated server error
+		Delivery: config.DeliveryConfig{Retry: config.RetryPolicy{MaxAttempts: 1}},
+	}
+	realService := services.NewFeishuService(cfg)
+	handler, wait := newTestHandler(t, realService, cfg)
 
 	payload := `{
 		"event_type": "new_entries",
@@ -503,10 +604,11 @@ func TestWebhookHandler_IntegrationWithRealService(t *testing.T) {
 	router := gin.New()
 	router.POST("/webhook", handler.HandleMinifluxWebhook)
 	router.ServeHTTP(w, req)
+	wait()
 
-	// Should still return OK even if the webhook call fails (error is logged)
-	if w.Code != http.StatusOK {
-		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	// Should still accept the webhook even if the webhook call fails (error is logged)
+	if w.Code != http.StatusAccepted {
+		t.Errorf("Expected status code %d, got %d", http.StatusAccepted, w.Code)
 	}
 
 	var response map[string]interface{}
@@ -515,7 +617,671 @@ func TestWebhookHandler_IntegrationWithRealService(t *testing.T) {
 		t.Fatalf("Failed to unmarshal response: %v", err)
 	}
 
-	if response["message"] != "Webhook processed successfully" {
+	if response["message"] != "Webhook accepted" {
 		t.Errorf("Expected success message, got %v", response["message"])
 	}
 }
+
+func TestWebhookHandler_HandleMinifluxWebhook_ConfiguredDestination(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := &MockFeishuService{}
+	cfg := &config.Config{
+		LegacyWebhookURL: true,
+		Destinations: map[string]*config.Destination{
+			"team": {Name: "team", WebhookURL: "https://hooks.example.com/webhook", Secret: "bot-secret"},
+		},
+	}
+	handler, wait := newTestHandler(t, mockService, cfg)
+
+	payload := `{
+		"event_type": "new_entries",
+		"feed": {"id": 8, "title": "Example website"},
+		"entries": [{"id": 231, "title": "Example", "url": "https://example.org/article"}]
+	}`
+	req := httptest.NewRequest("POST", "/webhook?webhook_url=https://hooks.example.com/webhook", bytes.NewBufferString(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Miniflux-Event-Type", "new_entries")
+
+	w := httptest.NewRecorder()
+	router := gin.New()
+	router.POST("/webhook", handler.HandleMinifluxWebhook)
+	router.ServeHTTP(w, req)
+	wait()
+
+	if w.Code != http.StatusAccepted {
+		t.Errorf("Expected status code %d, got %d", http.StatusAccepted, w.Code)
+	}
+
+	if mockService.lastDestination == nil || mockService.lastDestination.Secret != "bot-secret" {
+		t.Errorf("Expected the configured destination with its signing secret, got %+v", mockService.lastDestination)
+	}
+}
+
+func TestWebhookHandler_HandleMinifluxWebhook_NamedDestination(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{Destinations: map[string]*config.Destination{
+		"team": {Name: "team", WebhookURL: "https://hooks.example.com/team", Secret: "bot-secret"},
+	}}
+
+	payload := `{
+		"event_type": "new_entries",
+		"feed": {"id": 8, "title": "Example website"},
+		"entries": [{"id": 231, "title": "Example", "url": "https://example.org/article"}]
+	}`
+
+	tests := []struct {
+		name           string
+		legacy         bool
+		path           string
+		expectedStatus int
+		expectedError  string
+		expectedURL    string
+	}{
+		{
+			name:           "known destination",
+			path:           "/webhook/miniflux/team",
+			expectedStatus: http.StatusAccepted,
+			expectedURL:    "https://hooks.example.com/team",
+		},
+		{
+			name:           "unknown destination",
+			path:           "/webhook/miniflux/other",
+			expectedStatus: http.StatusNotFound,
+			expectedError:  `unknown destination "other"`,
+		},
+		{
+			name:           "webhook_url parameter without legacy mode",
+			path:           "/webhook/miniflux?webhook_url=https://hooks.example.com/team",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "webhook_url parameter is disabled, use /webhook/miniflux/:destination",
+		},
+		{
+			name:           "no destination",
+			path:           "/webhook/miniflux",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "destination is required",
+		},
+		{
+			name:           "webhook_url parameter in legacy mode",
+			legacy:         true,
+			path:           "/webhook/miniflux?webhook_url=https://hooks.example.com/legacy",
+			expectedStatus: http.StatusAccepted,
+			expectedURL:    "https://hooks.example.com/legacy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockFeishuService{}
+			testCfg := *cfg
+			testCfg.LegacyWebhookURL = tt.legacy
+			handler, wait := newTestHandler(t, mockService, &testCfg)
+
+			req := httptest.NewRequest("POST", tt.path, bytes.NewBufferString(payload))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Miniflux-Event-Type", "new_entries")
+
+			w := httptest.NewRecorder()
+			router := gin.New()
+			router.POST("/webhook/miniflux", handler.HandleMinifluxWebhook)
+			router.POST("/webhook/miniflux/:destination", handler.HandleMinifluxWebhook)
+			router.ServeHTTP(w, req)
+			wait()
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			if tt.expectedError != "" {
+				var response map[string]interface{}
+				if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+					t.Fatalf("Failed to unmarshal response: %v", err)
+				}
+				if response["error"] != tt.expectedError {
+					t.Errorf("Expected error %q, got %v", tt.expectedError, response["error"])
+				}
+				if mockService.callCount != 0 {
+					t.Errorf("Expected FeishuService not to be called, but it was called %d times", mockService.callCount)
+				}
+				return
+			}
+
+			if mockService.lastWebhookURL != tt.expectedURL {
+				t.Errorf("Expected webhook URL %q, got %q", tt.expectedURL, mockService.lastWebhookURL)
+			}
+		})
+	}
+}
+
+func TestWebhookHandler_HandleMinifluxWebhook_EnclosureFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := &MockFeishuService{}
+	cfg := &config.Config{Destinations: map[string]*config.Destination{
+		"podcasts": {
+			Name:       "podcasts",
+			WebhookURL: "https://hooks.example.com/podcasts",
+			Filter:     config.FilterConfig{EnclosureTypes: []string{"audio"}},
+		},
+	}}
+	handler, wait := newTestHandler(t, mockService, cfg)
+
+	payload := `{
+		"event_type": "new_entries",
+		"feed": {"id": 8, "title": "Example podcast"},
+		"entries": [
+			{"id": 1, "title": "Show notes", "url": "https://example.org/notes"},
+			{"id": 2, "title": "Episode 1", "url": "https://example.org/1", "enclosures": [{"url": "https://example.org/1.mp3", "mime_type": "audio/mpeg"}]},
+			{"id": 3, "title": "Cover art", "url": "https://example.org/cover", "enclosures": [{"url": "https://example.org/cover.jpg", "mime_type": "image/jpeg"}]}
+		]
+	}`
+	req := httptest.NewRequest("POST", "/webhook/miniflux/podcasts", bytes.NewBufferString(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Miniflux-Event-Type", "new_entries")
+
+	w := httptest.NewRecorder()
+	router := gin.New()
+	router.POST("/webhook/miniflux/:destination", handler.HandleMinifluxWebhook)
+	router.ServeHTTP(w, req)
+	wait()
+
+	if w.Code != http.StatusAccepted {
+		t.Errorf("Expected status code %d, got %d", http.StatusAccepted, w.Code)
+	}
+	if mockService.callCount != 1 {
+		t.Fatalf("Expected only the audio entry to be sent, got %d calls", mockService.callCount)
+	}
+	if mockService.lastEntry.ID != 2 {
+		t.Errorf("Expected entry 2 to be sent, got %d", mockService.lastEntry.ID)
+	}
+}
+
+func TestWebhookHandler_HandleMinifluxWebhook_KeywordFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := &MockFeishuService{}
+	filter := config.FilterConfig{
+		Include: []string{"CVE", "0day"},
+		Exclude: []string{"sponsored"},
+	}
+	if err := filter.Compile(); err != nil {
+		t.Fatalf("Failed to compile filter: %v", err)
+	}
+	cfg := &config.Config{Destinations: map[string]*config.Destination{
+		"security": {Name: "security", WebhookURL: "https://hooks.example.com/security", Filter: filter},
+	}}
+	handler, wait := newTestHandler(t, mockService, cfg)
+
+	payload := `{
+		"event_type": "new_entries",
+		"feed": {"id": 8, "title": "Security news"},
+		"entries": [
+			{"id": 1, "title": "CVE-2024-1234 in OpenSSL", "url": "https://example.org/1"},
+			{"id": 2, "title": "Weekly roundup", "url": "https://example.org/2"},
+			{"id": 3, "title": "0day scanner", "url": "https://example.org/3", "tags": ["Sponsored"]}
+		]
+	}`
+	req := httptest.NewRequest("POST", "/webhook/miniflux/security", bytes.NewBufferString(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Miniflux-Event-Type", "new_entries")
+
+	w := httptest.NewRecorder()
+	router := gin.New()
+	router.POST("/webhook/miniflux/:destination", handler.HandleMinifluxWebhook)
+	router.ServeHTTP(w, req)
+	wait()
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status code %d, got %d", http.StatusAccepted, w.Code)
+	}
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response["queued"] != float64(1) || response["filtered"] != float64(2) {
+		t.Errorf("Expected 1 queued and 2 filtered, got %v", response)
+	}
+	if mockService.callCount != 1 || mockService.lastEntry.ID != 1 {
+		t.Errorf("Expected only entry 1 to be sent, got %d calls", mockService.callCount)
+	}
+}
+
+func TestWebhookHandler_HandleMinifluxWebhook_Routing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var sent []string
+	mockService := &MockFeishuService{
+		sendEntryToFeishuFunc: func(entry *models.WebhookEntry, feed *models.WebhookFeed, dest *config.Destination) error {
+			sent = append(sent, fmt.Sprintf("%d:%s", entry.ID, dest.Name))
+			return nil
+		},
+	}
+	cfg := &config.Config{
+		Destinations: map[string]*config.Destination{
+			"releases": {Name: "releases", WebhookURL: "https://hooks.example.com/releases"},
+			"go":       {Name: "go", WebhookURL: "https://hooks.example.com/go"},
+			"inbox":    {Name: "inbox", WebhookURL: "https://hooks.example.com/inbox"},
+		},
+		Routing: config.RoutingConfig{
+			Rules: []config.RoutingRule{
+				{Match: config.RouteMatch{Title: `^Release v\d`}, Destinations: []string{"releases"}},
+				{Match: config.RouteMatch{Categories: []string{"golang"}}, Destinations: []string{"go", "releases"}},
+			},
+			Fallback: "inbox",
+		},
+	}
+	handler, wait := newTestHandler(t, mockService, cfg)
+
+	payload := `{
+		"event_type": "new_entries",
+		"feed": {"id": 8, "title": "Go blog", "category": {"id": 2, "title": "Golang"}},
+		"entries": [
+			{"id": 1, "title": "Release v1.22", "url": "https://example.org/1"}
+		]
+	}`
+	other := `{
+		"event_type": "new_entries",
+		"feed": {"id": 9, "title": "Other"},
+		"entries": [
+			{"id": 2, "title": "Release v2", "url": "https://example.org/2"},
+			{"id": 3, "title": "Weekly notes", "url": "https://example.org/3"}
+		]
+	}`
+
+	router := gin.New()
+	router.POST("/webhook/miniflux", handler.HandleMinifluxWebhook)
+	for _, body := range []string{payload, other} {
+		req := httptest.NewRequest("POST", "/webhook/miniflux", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Miniflux-Event-Type", "new_entries")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusAccepted {
+			t.Errorf("Expected status code %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+		}
+	}
+	wait()
+
+	// Destinations are delivered independently, so only the set is fixed
+	slices.Sort(sent)
+	expected := []string{"1:go", "1:releases", "2:releases", "3:inbox"}
+	if !slices.Equal(sent, expected) {
+		t.Errorf("Expected deliveries %v, got %v", expected, sent)
+	}
+}
+
+func TestWebhookHandler_HandleMinifluxWebhook_FanOut(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var sent []string
+	mockService := &MockFeishuService{
+		sendEntryToFeishuFunc: func(entry *models.WebhookEntry, feed *models.WebhookFeed, dest *config.Destination) error {
+			sent = append(sent, fmt.Sprintf("%d:%s", entry.ID, dest.Name))
+			return nil
+		},
+		validateFunc: func(webhookURL string) error {
+			if strings.Contains(webhookURL, "blocked") {
+				return services.ErrWebhookURLNotAllowed
+			}
+			return nil
+		},
+	}
+	cfg := &config.Config{Destinations: map[string]*config.Destination{
+		"team":    {Name: "team", WebhookURL: "https://hooks.example.com/team"},
+		"archive": {Name: "archive", WebhookURL: "https://hooks.example.com/archive", Filter: config.FilterConfig{EnclosureTypes: []string{"audio"}}},
+		"old":     {Name: "old", WebhookURL: "https://blocked.example.com/old"},
+	}}
+	handler, wait := newTestHandler(t, mockService, cfg)
+
+	payload := `{
+		"event_type": "new_entries",
+		"feed": {"id": 8, "title": "Example podcast"},
+		"entries": [
+			{"id": 1, "title": "Show notes", "url": "https://example.org/notes"},
+			{"id": 2, "title": "Episode 1", "url": "https://example.org/1", "enclosures": [{"url": "https://example.org/1.mp3", "mime_type": "audio/mpeg"}]}
+		]
+	}`
+	req := httptest.NewRequest("POST", "/webhook/miniflux/team,archive,old", bytes.NewBufferString(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Miniflux-Event-Type", "new_entries")
+
+	w := httptest.NewRecorder()
+	router := gin.New()
+	router.POST("/webhook/miniflux/:destination", handler.HandleMinifluxWebhook)
+	router.ServeHTTP(w, req)
+	wait()
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	var response struct {
+		Queued       int                           `json:"queued"`
+		Filtered     int                           `json:"filtered"`
+		Destinations map[string]*destinationResult `json:"destinations"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.Queued != 3 || response.Filtered != 1 {
+		t.Errorf("Expected 3 queued and 1 filtered, got %d and %d", response.Queued, response.Filtered)
+	}
+	expected := map[string]destinationResult{
+		"team":    {Queued: 2},
+		"archive": {Queued: 1, Filtered: 1},
+		"old":     {Error: services.ErrWebhookURLNotAllowed.Error()},
+	}
+	for name, want := range expected {
+		if got := response.Destinations[name]; got == nil || *got != want {
+			t.Errorf("Expected %s result %+v, got %+v", name, want, got)
+		}
+	}
+
+	slices.Sort(sent)
+	if want := []string{"1:team", "2:archive", "2:team"}; !slices.Equal(sent, want) {
+		t.Errorf("Expected deliveries %v, got %v", want, sent)
+	}
+}
+
+func TestWebhookHandler_HandleMinifluxWebhook_DisallowedWebhookURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Use the real service so the default allowlist and address checks apply
+	cfg := &config.Config{LegacyWebhookURL: true}
+	handler, wait := newTestHandler(t, services.NewFeishuService(cfg), cfg)
+
+	payload := `{
+		"event_type": "new_entries",
+		"feed": {"id": 8, "title": "Example website"},
+		"entries": [{"id": 231, "title": "Example", "url": "https://example.org/article"}]
+	}`
+
+	tests := []struct {
+		name       string
+		webhookURL string
+	}{
+		{name: "host outside the allowlist", webhookURL: "https://hooks.example.com/webhook"},
+		{name: "cloud metadata address", webhookURL: "http://169.254.169.254/latest/meta-data/"},
+		{name: "loopback address", webhookURL: "http://127.0.0.1:8080/admin"},
+		{name: "unsupported scheme", webhookURL: "file:///etc/passwd"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/webhook?webhook_url="+url.QueryEscape(tt.webhookURL), bytes.NewBufferString(payload))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Miniflux-Event-Type", "new_entries")
+
+			w := httptest.NewRecorder()
+			router := gin.New()
+			router.POST("/webhook", handler.HandleMinifluxWebhook)
+			router.ServeHTTP(w, req)
+			wait()
+
+			if w.Code != http.StatusForbidden {
+				t.Errorf("Expected status code %d, got %d", http.StatusForbidden, w.Code)
+			}
+
+			var response map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if msg, _ := response["error"].(string); !strings.HasPrefix(msg, "webhook URL not allowed") {
+				t.Errorf("Expected 'webhook URL not allowed' error, got %v", response["error"])
+			}
+		})
+	}
+}
+
+// fullQueue rejects every batch like a saturated dispatcher would
+type fullQueue struct{}
+
+func (fullQueue) Enqueue(jobs []*services.DeliveryJob) error {
+	return services.ErrQueueFull
+}
+
+func TestWebhookHandler_HandleMinifluxWebhook_QueueFull(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{LegacyWebhookURL: true}
+	cfg.Delivery.RetryAfter = 45 * time.Second
+	handler := NewWebhookHandler(&MockFeishuService{}, fullQueue{}, &services.EntryRouter{}, cfg)
+
+	payload := `{
+		"event_type": "new_entries",
+		"feed": {"id": 8, "title": "Example website"},
+		"entries": [{"id": 231, "title": "Example", "url": "https://example.org/article"}]
+	}`
+	req := httptest.NewRequest("POST", "/webhook?webhook_url=https://hooks.example.com/webhook", bytes.NewBufferString(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Miniflux-Event-Type", "new_entries")
+
+	w := httptest.NewRecorder()
+	router := gin.New()
+	router.POST("/webhook", handler.HandleMinifluxWebhook)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status code %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+
+	if w.Header().Get("Retry-After") != "45" {
+		t.Errorf("Expected Retry-After 45, got %q", w.Header().Get("Retry-After"))
+	}
+}
+
+func TestWebhookHandler_HandleMinifluxWebhook_MissingFeed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := &MockFeishuService{}
+	handler, wait := newTestHandler(t, mockService, &config.Config{LegacyWebhookURL: true})
+
+	payload := `{"event_type": "new_entries", "entries": [{"id": 231, "title": "Example"}]}`
+	req := httptest.NewRequest("POST", "/webhook?webhook_url=https://hooks.example.com/webhook", bytes.NewBufferString(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Miniflux-Event-Type", "new_entries")
+
+	w := httptest.NewRecorder()
+	router := gin.New()
+	router.POST("/webhook", handler.HandleMinifluxWebhook)
+	router.ServeHTTP(w, req)
+	wait()
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	if mockService.callCount != 0 {
+		t.Errorf("Expected FeishuService not to be called, but it was called %d times", mockService.callCount)
+	}
+}
+
+func signPayload(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhookHandler_HandleMinifluxWebhook_Signature(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	payload := `{
+		"event_type": "new_entries",
+		"feed": {"id": 8, "title": "Example website"},
+		"entries": [{"id": 231, "title": "Example", "url": "https://example.org/article"}]
+	}`
+	tampered := strings.Replace(payload, "https://example.org/article", "https://evil.example.com/phishing", 1)
+
+	sources := []config.MinifluxSource{
+		{Name: "home", Secret: "home-secret"},
+		{Name: "work", Secret: "work-secret"},
+	}
+
+	tests := []struct {
+		name             string
+		requireSignature bool
+		sources          []config.MinifluxSource
+		body             string
+		signature        string
+		expectedStatus   int
+		expectedError    string
+		expectedCalls    int
+	}{
+		{
+			name:           "valid signature from first source",
+			sources:        sources,
+			body:           payload,
+			signature:      signPayload("home-secret", payload),
+			expectedStatus: http.StatusAccepted,
+			expectedCalls:  1,
+		},
+		{
+			name:           "valid signature from second source",
+			sources:        sources,
+			body:           payload,
+			signature:      signPayload("work-secret", payload),
+			expectedStatus: http.StatusAccepted,
+			expectedCalls:  1,
+		},
+		{
+			name:           "tampered body",
+			sources:        sources,
+			body:           tampered,
+			signature:      signPayload("home-secret", payload),
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "invalid signature",
+		},
+		{
+			name:           "unknown secret",
+			sources:        sources,
+			body:           payload,
+			signature:      signPayload("attacker-secret", payload),
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "invalid signature",
+		},
+		{
+			name:           "malformed signature",
+			sources:        sources,
+			body:           payload,
+			signature:      "not-hex",
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "invalid signature",
+		},
+		{
+			name:             "unsigned request in strict mode",
+			requireSignature: true,
+			sources:          sources,
+			body:             payload,
+			expectedStatus:   http.StatusUnauthorized,
+			expectedError:    "missing signature",
+		},
+		{
+			name:           "unsigned request in non-strict mode",
+			sources:        sources,
+			body:           payload,
+			expectedStatus: http.StatusAccepted,
+			expectedCalls:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockFeishuService{}
+			cfg := &config.Config{
+				LegacyWebhookURL: true,
+				Miniflux: config.MinifluxConfig{
+					RequireSignature: tt.requireSignature,
+					Sources:          tt.sources,
+				},
+			}
+			handler, wait := newTestHandler(t, mockService, cfg)
+
+			req := httptest.NewRequest("POST", "/webhook?webhook_url=https://hooks.example.com/webhook", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Miniflux-Event-Type", "new_entries")
+			if tt.signature != "" {
+				req.Header.Set("X-Miniflux-Signature", tt.signature)
+			}
+
+			w := httptest.NewRecorder()
+			router := gin.New()
+			router.POST("/webhook", handler.HandleMinifluxWebhook)
+			router.ServeHTTP(w, req)
+			wait()
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			if tt.expectedError != "" {
+				var response map[string]interface{}
+				if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+					t.Fatalf("Failed to unmarshal response: %v", err)
+				}
+				if response["error"] != tt.expectedError {
+					t.Errorf("Expected error %q, got %v", tt.expectedError, response["error"])
+				}
+			}
+
+			if mockService.callCount != tt.expectedCalls {
+				t.Errorf("Expected FeishuService to be called %d times, got %d", tt.expectedCalls, mockService.callCount)
+			}
+		})
+	}
+}
+
+// saturatedQueue rejects the jobs of one destination and accepts the rest
+type saturatedQueue struct {
+	full string
+}
+
+func (q saturatedQueue) Enqueue(jobs []*services.DeliveryJob) error {
+	for _, job := range jobs {
+		if job.Destination.Name == q.full {
+			return &services.QueueFullError{Destinations: []string{q.full}}
+		}
+	}
+	return nil
+}
+
+func TestWebhookHandler_HandleMinifluxWebhook_PartialQueueFull(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{Destinations: map[string]*config.Destination{
+		"team":    {Name: "team", WebhookURL: "https://hooks.example.com/team"},
+		"archive": {Name: "archive", WebhookURL: "https://hooks.example.com/archive"},
+	}}
+	handler := NewWebhookHandler(&MockFeishuService{}, saturatedQueue{full: "archive"}, &services.EntryRouter{}, cfg)
+
+	payload := `{
+		"event_type": "new_entries",
+		"feed": {"id": 8, "title": "Example website"},
+		"entries": [{"id": 231, "title": "Example", "url": "https://example.org/article"}]
+	}`
+	req := httptest.NewRequest("POST", "/webhook/miniflux/team,archive", bytes.NewBufferString(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Miniflux-Event-Type", "new_entries")
+
+	w := httptest.NewRecorder()
+	router := gin.New()
+	router.POST("/webhook/miniflux/:destination", handler.HandleMinifluxWebhook)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	var response struct {
+		Queued       int                           `json:"queued"`
+		Destinations map[string]*destinationResult `json:"destinations"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.Queued != 1 {
+		t.Errorf("Expected 1 queued entry, got %d", response.Queued)
+	}
+	if got := response.Destinations["archive"]; got == nil || got.Queued != 0 || got.Error != services.ErrQueueFull.Error() {
+		t.Errorf("Expected archive to report a full queue, got %+v", got)
+	}
+}
@@ -0,0 +1,179 @@
+// Package i18n holds the translations of the fixed strings shown in messages,
+// such as button labels, and the locale's date formats.
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultLocale is used when neither the destination nor the configuration
+// sets a locale.
+const DefaultLocale = "en-US"
+
+// Message keys.
+const (
+	Feed        = "feed"
+	Author      = "author"
+	Published   = "published"
+	ReadingTime = "reading_time"
+	OpenArticle = "open_article"
+	Comments    = "comments"
+	Play        = "play"
+	Download    = "download"
+	JustNow     = "just_now"
+	Everyone    = "everyone"
+	Image       = "image"
+	// Plural keys, formatted with a count
+	MinutesAgo = "minutes_ago"
+	HoursAgo   = "hours_ago"
+	DaysAgo    = "days_ago"
+	Minutes    = "minutes"
+)
+
+// relativeTimeLimit is the age after which only the date is shown.
+const relativeTimeLimit = 7 * 24 * time.Hour
+
+// Locale is the message catalog of one language.
+type Locale struct {
+	Tag string
+	// DateLayout formats times for time.Time.Format
+	DateLayout string
+	messages   map[string]string
+	// plurals holds the one and other forms of the plural keys
+	plurals map[string][2]string
+}
+
+var locales = map[string]*Locale{
+	"en-US": {
+		Tag:        "en-US",
+		DateLayout: "Jan 2, 2006 15:04",
+		messages: map[string]string{
+			Feed:        "Feed",
+			Author:      "Author",
+			Published:   "Published",
+			ReadingTime: "Reading time",
+			OpenArticle: "Open article",
+			Comments:    "Comments",
+			Play:        "Play",
+			Download:    "Download",
+			JustNow:     "just now",
+			Everyone:    "Everyone",
+			Image:       "image",
+		},
+		plurals: map[string][2]string{
+			MinutesAgo: {"%d minute ago", "%d minutes ago"},
+			HoursAgo:   {"%d hour ago", "%d hours ago"},
+			DaysAgo:    {"%d day ago", "%d days ago"},
+			Minutes:    {"%d min", "%d min"},
+		},
+	},
+	"zh-CN": {
+		Tag:        "zh-CN",
+		DateLayout: "2006年1月2日 15:04",
+		messages: map[string]string{
+			Feed:        "订阅源",
+			Author:      "作者",
+			Published:   "发布时间",
+			ReadingTime: "阅读时长",
+			OpenArticle: "阅读原文",
+			Comments:    "评论",
+			Play:        "播放",
+			Download:    "下载",
+			JustNow:     "刚刚",
+			Everyone:    "所有人",
+			Image:       "图片",
+		},
+		plurals: map[string][2]string{
+			MinutesAgo: {"%d 分钟前", "%d 分钟前"},
+			HoursAgo:   {"%d 小时前", "%d 小时前"},
+			DaysAgo:    {"%d 天前", "%d 天前"},
+			Minutes:    {"%d 分钟", "%d 分钟"},
+		},
+	},
+}
+
+// Lookup returns the locale of a language tag such as "zh-CN", "zh_cn" or
+// "en". Unknown tags get the DefaultLocale and ok set to false.
+func Lookup(tag string) (locale *Locale, ok bool) {
+	tag = strings.ReplaceAll(strings.TrimSpace(tag), "_", "-")
+	for key, l := range locales {
+		if strings.EqualFold(key, tag) {
+			return l, true
+		}
+	}
+	// Fall back to the language alone, "zh-TW" still reads zh-CN better than
+	// English
+	language, _, _ := strings.Cut(tag, "-")
+	for key, l := range locales {
+		if prefix, _, _ := strings.Cut(key, "-"); language != "" && strings.EqualFold(prefix, language) {
+			return l, true
+		}
+	}
+	return locales[DefaultLocale], false
+}
+
+// Supported lists the available locale tags.
+func Supported() []string {
+	tags := make([]string, 0, len(locales))
+	for tag := range locales {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// T returns the translation of key, or the key itself if it has none.
+func (l *Locale) T(key string) string {
+	if message, ok := l.messages[key]; ok {
+		return message
+	}
+	return key
+}
+
+// N formats the plural key with n.
+func (l *Locale) N(key string, n int) string {
+	forms, ok := l.plurals[key]
+	if !ok {
+		return fmt.Sprintf("%s %d", key, n)
+	}
+	if n == 1 {
+		return fmt.Sprintf(forms[0], n)
+	}
+	return fmt.Sprintf(forms[1], n)
+}
+
+// FormatTime formats t with the locale's date layout.
+func (l *Locale) FormatTime(t time.Time) string {
+	return t.Format(l.DateLayout)
+}
+
+// RelativeTime describes how long ago t was, such as "3 hours ago". It returns
+// "" for times more than a week ago, which read better as a date.
+func (l *Locale) RelativeTime(t, now time.Time) string {
+	age := now.Sub(t)
+	switch {
+	case age >= relativeTimeLimit:
+		return ""
+	case age < time.Minute:
+		return l.T(JustNow)
+	case age < time.Hour:
+		return l.N(MinutesAgo, int(age/time.Minute))
+	case age < 24*time.Hour:
+		return l.N(HoursAgo, int(age/time.Hour))
+	default:
+		return l.N(DaysAgo, int(age/(24*time.Hour)))
+	}
+}
+
+// FormatPublished formats a publish time, followed by its relative time when
+// it is recent.
+func (l *Locale) FormatPublished(t, now time.Time) string {
+	formatted := l.FormatTime(t)
+	if relative := l.RelativeTime(t, now); relative != "" {
+		formatted += " (" + relative + ")"
+	}
+	return formatted
+}
@@ -0,0 +1,76 @@
+package i18n
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		tag        string
+		expected   string
+		expectedOK bool
+	}{
+		{tag: "zh-CN", expected: "zh-CN", expectedOK: true},
+		{tag: "zh_cn", expected: "zh-CN", expectedOK: true},
+		{tag: "zh", expected: "zh-CN", expectedOK: true},
+		{tag: "en-GB", expected: "en-US", expectedOK: true},
+		{tag: "fr-FR", expected: DefaultLocale, expectedOK: false},
+		{tag: "", expected: DefaultLocale, expectedOK: false},
+	}
+
+	for _, tt := range tests {
+		locale, ok := Lookup(tt.tag)
+		if locale.Tag != tt.expected || ok != tt.expectedOK {
+			t.Errorf("Expected Lookup(%q) = %s, %v, got %s, %v", tt.tag, tt.expected, tt.expectedOK, locale.Tag, ok)
+		}
+	}
+}
+
+func TestLocale_Messages(t *testing.T) {
+	for _, tag := range Supported() {
+		locale, _ := Lookup(tag)
+		for _, key := range []string{Feed, Author, Published, ReadingTime, OpenArticle, Comments, Play, Download, JustNow} {
+			if locale.T(key) == key {
+				t.Errorf("Expected %s to translate %q", tag, key)
+			}
+		}
+		for _, key := range []string{MinutesAgo, HoursAgo, DaysAgo, Minutes} {
+			if _, ok := locale.plurals[key]; !ok {
+				t.Errorf("Expected %s to have plural forms for %q", tag, key)
+			}
+		}
+	}
+}
+
+func TestLocale_RelativeTime(t *testing.T) {
+	now := time.Date(2023, 8, 17, 12, 0, 0, 0, time.UTC)
+	english, _ := Lookup("en-US")
+	chinese, _ := Lookup("zh-CN")
+
+	tests := []struct {
+		age     time.Duration
+		english string
+		chinese string
+	}{
+		{age: 10 * time.Second, english: "just now", chinese: "刚刚"},
+		{age: time.Minute, english: "1 minute ago", chinese: "1 分钟前"},
+		{age: 3*time.Hour + 20*time.Minute, english: "3 hours ago", chinese: "3 小时前"},
+		{age: 50 * time.Hour, english: "2 days ago", chinese: "2 天前"},
+		{age: 8 * 24 * time.Hour, english: "", chinese: ""},
+	}
+
+	for _, tt := range tests {
+		if result := english.RelativeTime(now.Add(-tt.age), now); result != tt.english {
+			t.Errorf("Expected %q for %s, got %q", tt.english, tt.age, result)
+		}
+		if result := chinese.RelativeTime(now.Add(-tt.age), now); result != tt.chinese {
+			t.Errorf("Expected %q for %s, got %q", tt.chinese, tt.age, result)
+		}
+	}
+
+	published := now.Add(-2 * time.Hour)
+	if result := english.FormatPublished(published, now); result != "Aug 17, 2023 10:00 (2 hours ago)" {
+		t.Errorf("Unexpected publish time %q", result)
+	}
+}
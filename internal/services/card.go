@@ -0,0 +1,172 @@
+package services
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/i18n"
+	"miniflux-feishu/internal/models"
+	"miniflux-feishu/internal/textutil"
+)
+
+// FeishuCard is the body of an "interactive" message.
+type FeishuCard struct {
+	Config   FeishuCardConfig    `json:"config"`
+	Header   FeishuCardHeader    `json:"header"`
+	Elements []FeishuCardElement `json:"elements"`
+}
+
+type FeishuCardConfig struct {
+	WideScreenMode bool `json:"wide_screen_mode"`
+}
+
+type FeishuCardHeader struct {
+	Template string         `json:"template"`
+	Title    FeishuCardText `json:"title"`
+}
+
+// FeishuCardText is a plain_text or lark_md text object.
+type FeishuCardText struct {
+	Tag     string `json:"tag"`
+	Content string `json:"content"`
+}
+
+// FeishuCardElement covers the card elements used here: div (with fields),
+// markdown, img, hr and action.
+type FeishuCardElement struct {
+	Tag     string              `json:"tag"`
+	Content string              `json:"content,omitempty"`
+	Fields  []FeishuCardField   `json:"fields,omitempty"`
+	Actions []FeishuCardElement `json:"actions,omitempty"`
+	// Button attributes, used inside an action element
+	Text *FeishuCardText `json:"text,omitempty"`
+	URL  string          `json:"url,omitempty"`
+	Type string          `json:"type,omitempty"`
+	// Image attributes
+	ImgKey string          `json:"img_key,omitempty"`
+	Alt    *FeishuCardText `json:"alt,omitempty"`
+}
+
+type FeishuCardField struct {
+	IsShort bool           `json:"is_short"`
+	Text    FeishuCardText `json:"text"`
+}
+
+func (s *FeishuService) formatEntryCard(entry *models.WebhookEntry, feed *models.WebhookFeed, dest *config.Destination) FeishuMessage {
+	locale := s.localeFor(dest)
+	var fields []FeishuCardField
+	fields = append(fields, cardField(locale.T(i18n.Feed), feed.Title))
+	if entry.Author != "" {
+		fields = append(fields, cardField(locale.T(i18n.Author), entry.Author))
+	}
+	if !entry.Date.IsZero() {
+		fields = append(fields, cardField(locale.T(i18n.Published), s.formatPublished(entry.Date, dest)))
+	}
+	var metadata entryMetadata
+	if dest.Metadata {
+		metadata = s.entryMetadata(entry, dest)
+		if metadata.ReadingTime != "" {
+			fields = append(fields, cardField(locale.T(i18n.ReadingTime), metadata.ReadingTime))
+		}
+	}
+
+	elements := []FeishuCardElement{{Tag: "div", Fields: fields}}
+	if summary := summarizeMarkdown(entry.Content, dest); summary != "" {
+		elements = append(elements, FeishuCardElement{Tag: "markdown", Content: summary})
+	}
+	if len(metadata.Hashtags) > 0 {
+		elements = append(elements, FeishuCardElement{Tag: "markdown", Content: markdownHashtags(metadata.Hashtags)})
+	}
+	if enclosures := describeEnclosures(entry.Enclosures, locale); len(enclosures) > 0 {
+		lines := make([]string, len(enclosures))
+		for i, e := range enclosures {
+			lines[i] = e.markdown()
+		}
+		elements = append(elements, FeishuCardElement{Tag: "markdown", Content: strings.Join(lines, "\n")})
+	}
+
+	// Feishu rejects cards with buttons it cannot open
+	var buttons []FeishuCardElement
+	if link := textutil.LinkURL(entry.URL); link != "" {
+		buttons = append(buttons, cardButton(locale.T(i18n.OpenArticle), link, "primary"))
+	}
+	if link := textutil.LinkURL(entry.CommentsURL); link != "" {
+		buttons = append(buttons, cardButton(locale.T(i18n.Comments), link, "default"))
+	}
+	if len(buttons) > 0 {
+		elements = append(elements, FeishuCardElement{Tag: "hr"}, FeishuCardElement{Tag: "action", Actions: buttons})
+	}
+
+	return FeishuMessage{
+		MsgType: "interactive",
+		Card: &FeishuCard{
+			Config: FeishuCardConfig{WideScreenMode: true},
+			Header: FeishuCardHeader{
+				Template: cardHeaderColor(feed, dest.Card),
+				Title:    FeishuCardText{Tag: "plain_text", Content: entry.Title},
+			},
+			Elements: elements,
+		},
+	}
+}
+
+// summarizeMarkdown converts the entry HTML to card markdown, cut to the
+// destination's summary length.
+func summarizeMarkdown(html string, dest *config.Destination) string {
+	summary := dest.Summary.WithDefaults(config.DefaultSummary)
+	return textutil.HTMLToMarkdown(html, textutil.MarkdownOptions{
+		MaxText:      summary.Length,
+		Ellipsis:     summary.Ellipsis,
+		DisplayWidth: summary.DisplayWidth,
+	})
+}
+
+// markdownHashtags joins hashtags for a markdown element, escaping the
+// underscores that join the words of a tag so that they do not turn into
+// italics.
+func markdownHashtags(tags []string) string {
+	escaped := make([]string, len(tags))
+	for i, tag := range tags {
+		escaped[i] = "#" + textutil.EscapeMarkdown(strings.TrimPrefix(tag, "#"))
+	}
+	return strings.Join(escaped, " ")
+}
+
+// cardField shows a label above its value. The value is escaped since it
+// comes from the feed.
+func cardField(label, value string) FeishuCardField {
+	return FeishuCardField{
+		IsShort: true,
+		Text:    FeishuCardText{Tag: "lark_md", Content: fmt.Sprintf("**%s**\n%s", label, textutil.EscapeMarkdown(value))},
+	}
+}
+
+func cardButton(label, url, buttonType string) FeishuCardElement {
+	return FeishuCardElement{
+		Tag:  "button",
+		Text: &FeishuCardText{Tag: "plain_text", Content: label},
+		URL:  url,
+		Type: buttonType,
+	}
+}
+
+// cardHeaderColor picks the configured color of the feed's category or title,
+// or else derives one from them so that each feed keeps a stable color.
+func cardHeaderColor(feed *models.WebhookFeed, card config.CardConfig) string {
+	key := feed.Title
+	if feed.Category != nil && feed.Category.Title != "" {
+		if color, ok := card.HeaderColors[feed.Category.Title]; ok {
+			return color
+		}
+		key = feed.Category.Title
+	}
+	if color, ok := card.HeaderColors[feed.Title]; ok {
+		return color
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(key)) //nolint:errcheck
+	return config.CardHeaderColors[h.Sum32()%uint32(len(config.CardHeaderColors))]
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/models"
+)
+
+func TestFeishuService_FormatEntryCard(t *testing.T) {
+	service := NewFeishuService(testConfig())
+
+	entry := &models.WebhookEntry{
+		ID:          231,
+		Title:       "Example",
+		URL:         "https://example.org/article",
+		CommentsURL: "https://news.example.org/item?id=1",
+		Author:      "Jane",
+		Date:        time.Date(2023, 8, 17, 19, 29, 22, 0, time.UTC),
+		Content:     "<p>Some HTML content</p>",
+	}
+	feed := &models.WebhookFeed{ID: 8, Title: "Example website"}
+	dest := &config.Destination{Name: "team", Format: config.FormatCard}
+
+	message, err := service.RenderMessage(entry, feed, dest)
+	if err != nil {
+		t.Fatalf("Failed to render message: %v", err)
+	}
+
+	if message.MsgType != "interactive" {
+		t.Errorf("Expected MsgType interactive, got %s", message.MsgType)
+	}
+	if message.Card == nil {
+		t.Fatalf("Expected a card")
+	}
+	if message.Card.Header.Title.Content != "Example" {
+		t.Errorf("Expected header title Example, got %q", message.Card.Header.Title.Content)
+	}
+
+	elements := message.Card.Elements
+	if len(elements) != 4 {
+		t.Fatalf("Expected div, markdown, hr and action elements, got %+v", elements)
+	}
+	fields := elements[0].Fields
+	if len(fields) != 3 {
+		t.Fatalf("Expected feed, author and published fields, got %+v", fields)
+	}
+	expectedFields := []string{"**Feed**\nExample website", "**Author**\nJane", "**Published**\nAug 17, 2023 19:29"}
+	for i, expected := range expectedFields {
+		if fields[i].Text.Content != expected {
+			t.Errorf("Expected field %d to be %q, got %q", i, expected, fields[i].Text.Content)
+		}
+	}
+	if elements[1].Tag != "markdown" || elements[1].Content != "Some HTML content" {
+		t.Errorf("Expected markdown summary, got %+v", elements[1])
+	}
+	buttons := elements[3].Actions
+	if len(buttons) != 2 || buttons[0].URL != entry.URL || buttons[1].URL != entry.CommentsURL {
+		t.Errorf("Expected article and comments buttons, got %+v", buttons)
+	}
+
+	// The summary keeps links and styles as markdown
+	rich := *entry
+	rich.Content = `<p>Read <a href="https://example.org/a">the <b>docs</b></a> for *details*.</p>`
+	if summary := service.formatEntryCard(&rich, feed, dest).Card.Elements[1].Content; summary != "Read [the **docs**](https://example.org/a) for &#42;details&#42;." {
+		t.Errorf("Expected markdown summary, got %q", summary)
+	}
+
+	// Field values are shown as is
+	markup := service.formatEntryCard(&models.WebhookEntry{Title: "Markup", Author: "[Jane](https://evil.example)"}, &models.WebhookFeed{Title: "**Big** news"}, dest)
+	if author := markup.Card.Elements[0].Fields[1].Text.Content; author != "**Author**\n&#91;Jane&#93;(https://evil.example)" {
+		t.Errorf("Expected an escaped author, got %q", author)
+	}
+	if title := markup.Card.Elements[0].Fields[0].Text.Content; title != "**Feed**\n&#42;&#42;Big&#42;&#42; news" {
+		t.Errorf("Expected an escaped feed title, got %q", title)
+	}
+
+	// Multi-word tags keep their underscores out of the markdown
+	tagged := service.formatEntryCard(&models.WebhookEntry{Title: "Tagged", Tags: []string{"Open Source", "Go Lang"}}, feed, &config.Destination{Format: config.FormatCard, Metadata: true})
+	if tags := tagged.Card.Elements[1].Content; tags != "#Open&#95;Source #Go&#95;Lang" {
+		t.Errorf("Expected escaped hashtags, got %q", tags)
+	}
+
+	// Links Feishu cannot open get no button
+	unsafe := service.formatEntryCard(&models.WebhookEntry{Title: "Unsafe", URL: "javascript:alert(1)", CommentsURL: "/item?id=1"}, feed, dest)
+	if last := unsafe.Card.Elements[len(unsafe.Card.Elements)-1]; last.Tag == "action" {
+		t.Errorf("Expected no buttons for unsafe links, got %+v", last.Actions)
+	}
+
+	// Optional parts are left out when the entry has no data for them
+	bare := service.formatEntryCard(&models.WebhookEntry{Title: "Bare"}, feed, dest)
+	if len(bare.Card.Elements) != 1 || len(bare.Card.Elements[0].Fields) != 1 {
+		t.Errorf("Expected only the feed field, got %+v", bare.Card.Elements)
+	}
+
+	// Labels and dates follow the destination's locale
+	service.now = func() time.Time { return entry.Date.Add(3 * time.Hour) }
+	localized := service.formatEntryCard(entry, feed, &config.Destination{Format: config.FormatCard, Locale: "zh-CN"})
+	if published := localized.Card.Elements[0].Fields[2].Text.Content; published != "**发布时间**\n2023年8月17日 19:29 (3 小时前)" {
+		t.Errorf("Expected a localized publish time, got %q", published)
+	}
+	if button := localized.Card.Elements[3].Actions[0].Text.Content; button != "阅读原文" {
+		t.Errorf("Expected a localized button label, got %q", button)
+	}
+
+	// Text stays the default format
+	if text, _ := service.RenderMessage(entry, feed, &config.Destination{}); text.MsgType != "text" || text.Card != nil {
+		t.Errorf("Expected a text message by default, got %+v", text)
+	}
+}
+
+func TestCardHeaderColor(t *testing.T) {
+	news := &models.WebhookCategory{ID: 1, Title: "News"}
+	card := config.CardConfig{HeaderColors: map[string]string{"News": "red", "Example website": "green"}}
+
+	tests := []struct {
+		name     string
+		feed     *models.WebhookFeed
+		expected string
+	}{
+		{"category color", &models.WebhookFeed{Title: "Example website", Category: news}, "red"},
+		{"feed color", &models.WebhookFeed{Title: "Example website"}, "green"},
+		{"feed color without category match", &models.WebhookFeed{Title: "Example website", Category: &models.WebhookCategory{Title: "Blogs"}}, "green"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if color := cardHeaderColor(tt.feed, card); color != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, color)
+			}
+		})
+	}
+
+	// Derived colors are valid and stable per category
+	first := cardHeaderColor(&models.WebhookFeed{Title: "One", Category: &models.WebhookCategory{Title: "Blogs"}}, config.CardConfig{})
+	second := cardHeaderColor(&models.WebhookFeed{Title: "Two", Category: &models.WebhookCategory{Title: "Blogs"}}, config.CardConfig{})
+	if first != second {
+		t.Errorf("Expected feeds of one category to share a color, got %s and %s", first, second)
+	}
+	if !slices.Contains(config.CardHeaderColors, first) {
+		t.Errorf("Expected a Feishu header template, got %s", first)
+	}
+}
+
+func TestFeishuService_SendEntryToFeishu_Card(t *testing.T) {
+	var capturedBody map[string]json.RawMessage
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&capturedBody); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		w.Write([]byte(`{"code":0,"msg":"success"}`)) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	service := NewFeishuService(testConfig())
+	entry := &models.WebhookEntry{ID: 231, Title: "Example", URL: "https://example.org/article"}
+	feed := &models.WebhookFeed{ID: 8, Title: "Example website"}
+	dest := &config.Destination{Name: "team", WebhookURL: server.URL, Format: config.FormatCard}
+
+	if err := service.SendEntryToFeishu(context.Background(), entry, feed, dest); err != nil {
+		t.Fatalf("Failed to send entry to Feishu: %v", err)
+	}
+
+	if string(capturedBody["msg_type"]) != `"interactive"` {
+		t.Errorf("Expected msg_type interactive, got %s", capturedBody["msg_type"])
+	}
+	if _, ok := capturedBody["content"]; ok {
+		t.Errorf("Expected no content field in a card message, got %s", capturedBody["content"])
+	}
+	if _, ok := capturedBody["card"]; !ok {
+		t.Errorf("Expected a card field")
+	}
+}
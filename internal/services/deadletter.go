@@ -0,0 +1,172 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var deadBucket = []byte("dead")
+
+// ErrDeadLetterNotFound is returned for IDs that are not in the dead-letter
+// store.
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// DeliveryAttempt records one failed attempt to send a job.
+type DeliveryAttempt struct {
+	Time       time.Time `json:"time"`
+	Kind       string    `json:"kind"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Code       int       `json:"code,omitempty"`
+	Error      string    `json:"error"`
+}
+
+func newDeliveryAttempt(at time.Time, err error) DeliveryAttempt {
+	attempt := DeliveryAttempt{
+		Time:  at,
+		Kind:  ErrorKind(err),
+		Error: err.Error(),
+	}
+	var sendErr *SendError
+	if errors.As(err, &sendErr) {
+		attempt.StatusCode = sendErr.StatusCode
+		attempt.Code = sendErr.Code
+	}
+	return attempt
+}
+
+// DeliveryError is returned by SendEntryToFeishu once it has given up on an
+// entry, and carries the failed attempts.
+type DeliveryError struct {
+	Attempts []DeliveryAttempt
+	Err      error
+}
+
+func (e *DeliveryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DeliveryError) Unwrap() error {
+	return e.Err
+}
+
+// DeadLetterFilter selects dead letters. Zero fields match everything.
+type DeadLetterFilter struct {
+	Destination string
+	FeedID      int64
+}
+
+func (f DeadLetterFilter) matches(job *DeliveryJob) bool {
+	if f.Destination != "" && job.DestinationName != f.Destination {
+		return false
+	}
+	if f.FeedID != 0 && (job.Feed == nil || job.Feed.ID != f.FeedID) {
+		return false
+	}
+	return true
+}
+
+// Bury moves a pending job to the dead-letter store after its last attempt
+// failed. Dead letters are kept until they are replayed or purged.
+func (o *Outbox) Bury(job *DeliveryJob, lastError string, attempts []DeliveryAttempt) error {
+	return o.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(pendingBucket).Delete(itob(job.ID)); err != nil {
+			return err
+		}
+		job.Status = JobStatusDead
+		job.LastError = lastError
+		job.Attempts = append(job.Attempts, attempts...)
+		job.FinishedAt = o.now()
+		return putJob(tx.Bucket(deadBucket), job)
+	})
+}
+
+// DeadLetters returns the dead letters matching filter, oldest first.
+func (o *Outbox) DeadLetters(filter DeadLetterFilter) ([]*DeliveryJob, error) {
+	var jobs []*DeliveryJob
+	err := o.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(deadBucket).ForEach(func(k, v []byte) error {
+			job, err := decodeJob(k, v)
+			if err != nil {
+				return err
+			}
+			if filter.matches(job) {
+				jobs = append(jobs, job)
+			}
+			return nil
+		})
+	})
+	return jobs, err
+}
+
+// DeadLetter returns a single dead letter.
+func (o *Outbox) DeadLetter(id uint64) (*DeliveryJob, error) {
+	var job *DeliveryJob
+	err := o.db.View(func(tx *bolt.Tx) error {
+		k := itob(id)
+		v := tx.Bucket(deadBucket).Get(k)
+		if v == nil {
+			return fmt.Errorf("%w: %d", ErrDeadLetterNotFound, id)
+		}
+		var err error
+		job, err = decodeJob(k, v)
+		return err
+	})
+	return job, err
+}
+
+// Resurrect moves dead letters back to the pending bucket, keeping their IDs
+// and attempt history. Either all jobs are moved or none.
+func (o *Outbox) Resurrect(jobs []*DeliveryJob) error {
+	return o.db.Update(func(tx *bolt.Tx) error {
+		dead := tx.Bucket(deadBucket)
+		pending := tx.Bucket(pendingBucket)
+		for _, job := range jobs {
+			k := itob(job.ID)
+			if dead.Get(k) == nil {
+				return fmt.Errorf("%w: %d", ErrDeadLetterNotFound, job.ID)
+			}
+			if err := dead.Delete(k); err != nil {
+				return err
+			}
+			job.Status = ""
+			job.FinishedAt = time.Time{}
+			if err := putJob(pending, job); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// PurgeDeadLetters deletes the given dead letters and returns how many
+// existed.
+func (o *Outbox) PurgeDeadLetters(ids []uint64) (int, error) {
+	purged := 0
+	err := o.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(deadBucket)
+		for _, id := range ids {
+			k := itob(id)
+			if bucket.Get(k) == nil {
+				continue
+			}
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+			purged++
+		}
+		return nil
+	})
+	return purged, err
+}
+
+func decodeJob(k, v []byte) (*DeliveryJob, error) {
+	var job DeliveryJob
+	if err := json.Unmarshal(v, &job); err != nil {
+		return nil, fmt.Errorf("failed to decode job %d: %w", btoi(k), err)
+	}
+	return &job, nil
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/models"
+)
+
+// failingSender fails every entry whose ID is in fail
+type failingSender struct {
+	recordingSender
+	fail map[int64]bool
+}
+
+func (f *failingSender) SendEntryToFeishu(ctx context.Context, entry *models.WebhookEntry, feed *models.WebhookFeed, dest *config.Destination) error {
+	if f.fail[entry.ID] {
+		return &DeliveryError{
+			Attempts: []DeliveryAttempt{
+				{Kind: "server_error", StatusCode: 500, Error: "first"},
+				{Kind: "server_error", StatusCode: 500, Error: "second"},
+			},
+			Err: errors.New("giving up after 2 attempts"),
+		}
+	}
+	return f.recordingSender.SendEntryToFeishu(ctx, entry, feed, dest)
+}
+
+func TestDispatcher_MovesFailedJobsToDeadLetters(t *testing.T) {
+	outbox := newTestOutbox(t, "")
+	sender := &failingSender{fail: map[int64]bool{2: true}}
+	cfg := &config.Config{
+		Delivery:     config.DeliveryConfig{Workers: 1, QueueSize: 10},
+		Destinations: map[string]*config.Destination{"team": {Name: "team"}},
+	}
+	dispatcher := newTestDispatcher(t, sender, outbox, cfg)
+	dispatcher.Start()
+
+	if err := dispatcher.Enqueue(newTestJobs(1, 2, 3)); err != nil {
+		t.Fatalf("Failed to enqueue jobs: %v", err)
+	}
+	if err := dispatcher.Stop(context.Background()); err != nil {
+		t.Fatalf("Failed to stop dispatcher: %v", err)
+	}
+
+	dead, err := outbox.DeadLetters(DeadLetterFilter{})
+	if err != nil {
+		t.Fatalf("Failed to list dead letters: %v", err)
+	}
+	if len(dead) != 1 || dead[0].Entry.ID != 2 {
+		t.Fatalf("Expected entry 2 to be dead-lettered, got %v", dead)
+	}
+	if dead[0].Status != JobStatusDead || dead[0].LastError != "giving up after 2 attempts" {
+		t.Errorf("Unexpected dead letter status %q, error %q", dead[0].Status, dead[0].LastError)
+	}
+	if len(dead[0].Attempts) != 2 || dead[0].Attempts[1].Error != "second" {
+		t.Errorf("Expected attempt history to be kept, got %+v", dead[0].Attempts)
+	}
+
+	pending, err := outbox.PendingAfter(0, 0)
+	if err != nil {
+		t.Fatalf("Failed to list pending jobs: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("Expected no pending jobs, got %v", pending)
+	}
+}
+
+func TestDispatcher_Replay(t *testing.T) {
+	outbox := newTestOutbox(t, "")
+	cfg := &config.Config{
+		Delivery:     config.DeliveryConfig{Workers: 1, QueueSize: 10},
+		Destinations: map[string]*config.Destination{"team": {Name: "team"}},
+	}
+
+	jobs := newTestJobs(1, 2)
+	jobs[1].Destination = &config.Destination{Name: "removed"}
+	if err := outbox.Add(jobs); err != nil {
+		t.Fatalf("Failed to add jobs: %v", err)
+	}
+	for _, job := range jobs {
+		if err := outbox.Bury(job, "boom", []DeliveryAttempt{{Error: "boom"}}); err != nil {
+			t.Fatalf("Failed to bury job: %v", err)
+		}
+	}
+
+	sender := &recordingSender{}
+	dispatcher := newTestDispatcher(t, sender, outbox, cfg)
+	dispatcher.Start()
+
+	if _, err := dispatcher.Replay([]uint64{jobs[1].ID}); !errors.Is(err, ErrUnknownDestination) {
+		t.Errorf("Expected ErrUnknownDestination, got %v", err)
+	}
+	if _, err := dispatcher.Replay([]uint64{99}); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Errorf("Expected ErrDeadLetterNotFound, got %v", err)
+	}
+	if replayed, err := dispatcher.Replay([]uint64{jobs[0].ID}); err != nil || replayed != 1 {
+		t.Fatalf("Failed to replay job: %d, %v", replayed, err)
+	}
+	if err := dispatcher.Stop(context.Background()); err != nil {
+		t.Fatalf("Failed to stop dispatcher: %v", err)
+	}
+
+	if len(sender.sent) != 1 || sender.sent[0] != 1 {
+		t.Errorf("Expected entry 1 to be redelivered, got %v", sender.sent)
+	}
+	dead, err := outbox.DeadLetters(DeadLetterFilter{})
+	if err != nil {
+		t.Fatalf("Failed to list dead letters: %v", err)
+	}
+	if len(dead) != 1 || dead[0].ID != jobs[1].ID {
+		t.Errorf("Expected only the job of the removed destination to stay dead, got %v", dead)
+	}
+}
+
+func TestDispatcher_ReplayFillsFreeCapacity(t *testing.T) {
+	outbox := newTestOutbox(t, "")
+	cfg := &config.Config{
+		Delivery:     config.DeliveryConfig{Workers: 1, QueueSize: 2},
+		Destinations: map[string]*config.Destination{"team": {Name: "team"}},
+	}
+
+	jobs := newTestJobs(1, 2, 3)
+	if err := outbox.Add(jobs); err != nil {
+		t.Fatalf("Failed to add jobs: %v", err)
+	}
+	ids := make([]uint64, 0, len(jobs))
+	for _, job := range jobs {
+		if err := outbox.Bury(job, "boom", nil); err != nil {
+			t.Fatalf("Failed to bury job: %v", err)
+		}
+		ids = append(ids, job.ID)
+	}
+
+	sender := &recordingSender{}
+	dispatcher := newTestDispatcher(t, sender, outbox, cfg)
+
+	// Workers are not started yet, so only two fit
+	if replayed, err := dispatcher.Replay(ids); err != nil || replayed != 2 {
+		t.Fatalf("Expected 2 replayed dead letters, got %d, %v", replayed, err)
+	}
+	if _, err := dispatcher.Replay(ids[2:]); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+
+	dispatcher.Start()
+	if err := dispatcher.Stop(context.Background()); err != nil {
+		t.Fatalf("Failed to stop dispatcher: %v", err)
+	}
+	if len(sender.sent) != 2 || sender.sent[0] != 1 || sender.sent[1] != 2 {
+		t.Errorf("Expected entries [1 2] to be redelivered, got %v", sender.sent)
+	}
+	dead, err := outbox.DeadLetters(DeadLetterFilter{})
+	if err != nil {
+		t.Fatalf("Failed to list dead letters: %v", err)
+	}
+	if len(dead) != 1 || dead[0].ID != jobs[2].ID {
+		t.Errorf("Expected entry 3 to stay dead, got %v", dead)
+	}
+}
+
+func TestOutbox_DeadLetterFilterAndPurge(t *testing.T) {
+	outbox := newTestOutbox(t, "")
+
+	jobs := newTestJobs(1, 2, 3)
+	jobs[1].Destination = &config.Destination{Name: "ops"}
+	jobs[2].Feed = &models.WebhookFeed{ID: 9}
+	if err := outbox.Add(jobs); err != nil {
+		t.Fatalf("Failed to add jobs: %v", err)
+	}
+	for _, job := range jobs {
+		if err := outbox.Bury(job, "boom", nil); err != nil {
+			t.Fatalf("Failed to bury job: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		filter   DeadLetterFilter
+		expected []int64
+	}{
+		{"all", DeadLetterFilter{}, []int64{1, 2, 3}},
+		{"destination", DeadLetterFilter{Destination: "team"}, []int64{1, 3}},
+		{"feed", DeadLetterFilter{FeedID: 9}, []int64{3}},
+		{"destination and feed", DeadLetterFilter{Destination: "ops", FeedID: 9}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dead, err := outbox.DeadLetters(tt.filter)
+			if err != nil {
+				t.Fatalf("Failed to list dead letters: %v", err)
+			}
+			var ids []int64
+			for _, job := range dead {
+				ids = append(ids, job.Entry.ID)
+			}
+			if len(ids) != len(tt.expected) {
+				t.Fatalf("Expected entries %v, got %v", tt.expected, ids)
+			}
+			for i := range ids {
+				if ids[i] != tt.expected[i] {
+					t.Errorf("Expected entries %v, got %v", tt.expected, ids)
+				}
+			}
+		})
+	}
+
+	// Dead letters outlive the retention period of delivered jobs
+	outbox.now = func() time.Time { return time.Now().Add(365 * 24 * time.Hour) }
+	if purged, err := outbox.PurgeFinished(); err != nil || purged != 0 {
+		t.Errorf("Expected PurgeFinished to leave dead letters alone, got %d, %v", purged, err)
+	}
+
+	purged, err := outbox.PurgeDeadLetters([]uint64{jobs[0].ID, 42})
+	if err != nil {
+		t.Fatalf("Failed to purge dead letters: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("Expected 1 purged dead letter, got %d", purged)
+	}
+	if _, err := outbox.DeadLetter(jobs[0].ID); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Errorf("Expected ErrDeadLetterNotFound after purge, got %v", err)
+	}
+}
@@ -0,0 +1,435 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/models"
+)
+
+// ErrQueueFull is returned when the delivery queue of a destination has no
+// room for its jobs.
+var ErrQueueFull = errors.New("delivery queue is full")
+
+// QueueFullError lists the destinations whose jobs Enqueue rejected because
+// their queue was full. The jobs of other destinations were queued.
+type QueueFullError struct {
+	Destinations []string
+}
+
+func (e *QueueFullError) Error() string {
+	return fmt.Sprintf("%v for %s", ErrQueueFull, strings.Join(e.Destinations, ", "))
+}
+
+func (e *QueueFullError) Is(target error) bool {
+	return target == ErrQueueFull
+}
+
+// ErrDispatcherStopped is returned by Enqueue once Stop has been called.
+var ErrDispatcherStopped = errors.New("dispatcher is stopped")
+
+// ErrUnknownDestination is returned when replaying a job whose destination was
+// removed from the configuration, or whose legacy webhook URL has not been
+// seen since the last restart.
+var ErrUnknownDestination = errors.New("destination is not configured")
+
+// purgeInterval is how often finished jobs past their retention are removed.
+const purgeInterval = time.Hour
+
+// EntrySender delivers a single entry to a destination.
+type EntrySender interface {
+	SendEntryToFeishu(ctx context.Context, entry *models.WebhookEntry, feed *models.WebhookFeed, dest *config.Destination) error
+}
+
+// DeliveryJob is one entry waiting to be sent to one destination.
+type DeliveryJob struct {
+	ID uint64 `json:"id"`
+	// Destination is resolved from DestinationName (or WebhookRef for legacy
+	// destinations) when a job is loaded back from the outbox.
+	Destination     *config.Destination `json:"-"`
+	DestinationName string              `json:"destination"`
+	// WebhookRef identifies the webhook URL of a legacy destination. The URL
+	// holds the bot token, so it is only kept in memory.
+	WebhookRef string               `json:"webhook_ref,omitempty"`
+	Feed       *models.WebhookFeed  `json:"feed"`
+	Entry      *models.WebhookEntry `json:"entry"`
+	CreatedAt  time.Time            `json:"created_at"`
+	Status     string               `json:"status,omitempty"`
+	LastError  string               `json:"last_error,omitempty"`
+	// Attempts lists the failed attempts, across replays.
+	Attempts   []DeliveryAttempt `json:"attempts,omitempty"`
+	FinishedAt time.Time         `json:"finished_at,omitzero"`
+}
+
+// Dispatcher delivers jobs in the background. Every destination has its own
+// lane with a fixed number of workers, so a destination that is rate limited
+// or retrying does not hold up the others. Jobs are written to the outbox
+// before Enqueue returns and are only removed from it once a worker is done
+// with them, so a restart resumes where the previous process stopped.
+type Dispatcher struct {
+	sender  EntrySender
+	outbox  *Outbox
+	config  *config.Config
+	workers int
+	// queueSize bounds the pending jobs of each lane
+	queueSize int
+	lanes     map[string]chan *DeliveryJob
+
+	mu      sync.Mutex
+	started bool
+	stopped bool
+	// pending counts the jobs of each lane that are queued or being sent
+	pending map[string]int
+	// webhooks maps the references of the legacy webhook URLs seen since
+	// start to the URLs
+	webhooks map[string]string
+	wg       sync.WaitGroup
+	janitor  sync.WaitGroup
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// NewDispatcher creates a dispatcher and queues the jobs left pending in the
+// outbox by a previous run.
+func NewDispatcher(sender EntrySender, outbox *Outbox, cfg *config.Config) (*Dispatcher, error) {
+	recovered, err := outbox.PendingAfter(0, -1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pending jobs: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		sender:    sender,
+		outbox:    outbox,
+		config:    cfg,
+		workers:   cfg.Delivery.Workers,
+		queueSize: cfg.Delivery.QueueSize,
+		lanes:     make(map[string]chan *DeliveryJob),
+		pending:   make(map[string]int),
+		webhooks:  make(map[string]string),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+
+	resumed := make([]*DeliveryJob, 0, len(recovered))
+	for _, job := range recovered {
+		if err := d.resolveDestination(job); err != nil {
+			log.Printf("Moving pending entry %d to the dead-letter store: %v", job.Entry.ID, err)
+			d.bury(job, err, nil)
+			continue
+		}
+		// Count first, lanes are sized by their pending jobs when created
+		d.pending[laneKey(job.Destination)]++
+		resumed = append(resumed, job)
+	}
+	for _, job := range resumed {
+		d.push(job)
+	}
+	if len(resumed) > 0 {
+		log.Printf("Resuming %d pending deliveries from the outbox", len(resumed))
+	}
+	return d, nil
+}
+
+// Start launches the workers of the existing lanes. Lanes created later start
+// right away.
+func (d *Dispatcher) Start() {
+	d.mu.Lock()
+	d.started = true
+	for _, lane := range d.lanes {
+		d.startLane(lane)
+	}
+	d.mu.Unlock()
+	d.janitor.Add(1)
+	go d.purge()
+}
+
+func (d *Dispatcher) startLane(lane chan *DeliveryJob) {
+	for i := 0; i < d.workers; i++ {
+		d.wg.Add(1)
+		go d.work(lane)
+	}
+}
+
+// push queues a job in the lane of its destination, creating the lane on
+// first use. The job must already be counted as pending. Lanes have room for
+// every pending job, so this never blocks. Callers hold d.mu, except while
+// the dispatcher is being created.
+func (d *Dispatcher) push(job *DeliveryJob) {
+	key := laneKey(job.Destination)
+	lane, ok := d.lanes[key]
+	if !ok {
+		// The pending jobs may exceed the queue size right after a restart
+		lane = make(chan *DeliveryJob, max(d.queueSize, d.pending[key]))
+		d.lanes[key] = lane
+		if d.started {
+			d.startLane(lane)
+		}
+	}
+	lane <- job
+}
+
+// laneKey returns the lane of a destination. Legacy destinations share one
+// lane, since every caller can make up a new webhook URL and lanes are never
+// torn down.
+func laneKey(dest *config.Destination) string {
+	if dest.Legacy {
+		return "legacy"
+	}
+	return dest.Name
+}
+
+// Enqueue stores the jobs in the outbox and queues them. Every destination
+// has its own queue: when the jobs of a destination do not all fit in it,
+// none of them are queued, so they can be resent as a whole, and the
+// destination is reported in a *QueueFullError. The jobs of the other
+// destinations are queued anyway.
+func (d *Dispatcher) Enqueue(jobs []*DeliveryJob) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopped {
+		return ErrDispatcherStopped
+	}
+	accepted, full := d.fit(jobs)
+	if len(accepted) > 0 {
+		for _, job := range accepted {
+			if job.Destination.Legacy {
+				d.webhooks[webhookRef(job.Destination.WebhookURL)] = job.Destination.WebhookURL
+			}
+		}
+		if err := d.outbox.Add(accepted); err != nil {
+			return fmt.Errorf("failed to store jobs: %w", err)
+		}
+		for _, job := range accepted {
+			d.pending[laneKey(job.Destination)]++
+			d.push(job)
+		}
+	}
+	if len(full) > 0 {
+		return &QueueFullError{Destinations: full}
+	}
+	return nil
+}
+
+// fit splits jobs into those whose lane has room for all of the lane's jobs
+// in the batch, and the names of the destinations whose lane has not.
+// Callers hold d.mu.
+func (d *Dispatcher) fit(jobs []*DeliveryJob) ([]*DeliveryJob, []string) {
+	counts := make(map[string]int)
+	for _, job := range jobs {
+		counts[laneKey(job.Destination)]++
+	}
+	accepted := make([]*DeliveryJob, 0, len(jobs))
+	var full []string
+	for _, job := range jobs {
+		key := laneKey(job.Destination)
+		if d.pending[key]+counts[key] > d.queueSize {
+			if !slices.Contains(full, job.Destination.Name) {
+				full = append(full, job.Destination.Name)
+			}
+			continue
+		}
+		accepted = append(accepted, job)
+	}
+	return accepted, full
+}
+
+// Stop stops accepting jobs and waits for the queued ones to be delivered. If
+// ctx expires first, in-flight deliveries are cancelled and the remaining
+// jobs stay in the outbox for the next start.
+func (d *Dispatcher) Stop(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.stopped {
+		d.stopped = true
+		for _, lane := range d.lanes {
+			close(lane)
+		}
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		d.cancel()
+		<-done
+		err = ctx.Err()
+	}
+	d.cancel()
+	d.janitor.Wait()
+	return err
+}
+
+func (d *Dispatcher) work(lane chan *DeliveryJob) {
+	defer d.wg.Done()
+	for job := range lane {
+		// After a shutdown timeout the rest of the queue is left for the next run
+		if d.ctx.Err() != nil {
+			continue
+		}
+		d.deliver(job)
+	}
+}
+
+func (d *Dispatcher) deliver(job *DeliveryJob) {
+	err := d.sender.SendEntryToFeishu(d.ctx, job.Entry, job.Feed, job.Destination)
+	if err != nil && d.ctx.Err() != nil {
+		log.Printf("Delivery of entry %d to %s interrupted by shutdown, keeping it pending", job.Entry.ID, job.Destination.Name)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to send entry %d to %s, moving it to the dead-letter store: %v", job.Entry.ID, job.Destination.Name, err)
+		destinationFailedMetrics.Add(job.Destination.Name, 1)
+		var deliveryErr *DeliveryError
+		if errors.As(err, &deliveryErr) {
+			d.bury(job, err, deliveryErr.Attempts)
+		} else {
+			d.bury(job, err, []DeliveryAttempt{newDeliveryAttempt(time.Now(), err)})
+		}
+	} else {
+		log.Printf("Successfully sent entry %d to %s", job.Entry.ID, job.Destination.Name)
+		destinationSentMetrics.Add(job.Destination.Name, 1)
+		if err := d.outbox.Finish(job); err != nil {
+			log.Printf("Failed to record delivery of job %d: %v", job.ID, err)
+		}
+	}
+
+	d.mu.Lock()
+	d.pending[laneKey(job.Destination)]--
+	d.mu.Unlock()
+}
+
+func (d *Dispatcher) bury(job *DeliveryJob, cause error, attempts []DeliveryAttempt) {
+	if err := d.outbox.Bury(job, cause.Error(), attempts); err != nil {
+		log.Printf("Failed to move job %d to the dead-letter store: %v", job.ID, err)
+	}
+}
+
+// Replay queues dead letters for delivery again, as many as fit in the queues
+// of their destinations, and returns how many were queued. The others stay in
+// the dead-letter store so that they can be replayed once the queues drain.
+// Nothing is queued if one of the dead letters is missing or its destination
+// no longer exists, and ErrQueueFull is returned when none of them fit.
+func (d *Dispatcher) Replay(ids []uint64) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopped {
+		return 0, ErrDispatcherStopped
+	}
+
+	jobs := make([]*DeliveryJob, 0, len(ids))
+	for _, id := range ids {
+		job, err := d.outbox.DeadLetter(id)
+		if err != nil {
+			return 0, err
+		}
+		if err := d.resolveDestination(job); err != nil {
+			return 0, fmt.Errorf("cannot replay job %d: %w", id, err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	// Take jobs in order while their lane has room
+	taken := make(map[string]int)
+	fitting := make([]*DeliveryJob, 0, len(jobs))
+	var full []string
+	for _, job := range jobs {
+		key := laneKey(job.Destination)
+		if d.pending[key]+taken[key] >= d.queueSize {
+			if !slices.Contains(full, job.Destination.Name) {
+				full = append(full, job.Destination.Name)
+			}
+			continue
+		}
+		taken[key]++
+		fitting = append(fitting, job)
+	}
+	if len(fitting) == 0 {
+		if len(full) == 0 {
+			return 0, nil
+		}
+		return 0, &QueueFullError{Destinations: full}
+	}
+	if err := d.outbox.Resurrect(fitting); err != nil {
+		return 0, err
+	}
+
+	for _, job := range fitting {
+		d.pending[laneKey(job.Destination)]++
+		d.push(job)
+	}
+	return len(fitting), nil
+}
+
+// resolveDestination restores the destination of a job loaded from the
+// outbox. Configured destinations are looked up again by name so that edits
+// to their settings apply to jobs queued before a restart. Legacy webhook URLs
+// are not stored, so their jobs can only be restored once Miniflux has sent
+// to the same URL again since the start.
+func (d *Dispatcher) resolveDestination(job *DeliveryJob) error {
+	if job.WebhookRef != "" {
+		webhookURL, ok := d.webhooks[job.WebhookRef]
+		if !ok {
+			return fmt.Errorf("%w: the webhook URL of %q is not kept across restarts", ErrUnknownDestination, job.DestinationName)
+		}
+		job.Destination = &config.Destination{
+			Name:       job.DestinationName,
+			WebhookURL: webhookURL,
+			Legacy:     true,
+			Retry:      d.config.Delivery.Retry,
+			RateLimit:  d.config.Delivery.RateLimit,
+			Location:   d.config.Location,
+		}
+		return nil
+	}
+	dest, ok := d.config.Destinations[job.DestinationName]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownDestination, job.DestinationName)
+	}
+	job.Destination = dest
+	return nil
+}
+
+// webhookRef returns the reference stored for a legacy webhook URL.
+func webhookRef(webhookURL string) string {
+	sum := sha256.Sum256([]byte(webhookURL))
+	return hex.EncodeToString(sum[:])
+}
+
+// purge removes expired delivered jobs at start and then periodically.
+func (d *Dispatcher) purge() {
+	defer d.janitor.Done()
+
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+	for {
+		if purged, err := d.outbox.PurgeFinished(); err != nil {
+			log.Printf("Failed to purge finished jobs: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d finished jobs from the outbox", purged)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
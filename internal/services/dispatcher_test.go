@@ -0,0 +1,342 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/models"
+)
+
+// recordingSender records delivered entry IDs and can block until released
+type recordingSender struct {
+	mu      sync.Mutex
+	sent    []int64
+	release chan struct{}
+}
+
+func (r *recordingSender) SendEntryToFeishu(ctx context.Context, entry *models.WebhookEntry, feed *models.WebhookFeed, dest *config.Destination) error {
+	if r.release != nil {
+		select {
+		case <-r.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, entry.ID)
+	return nil
+}
+
+func newTestOutbox(t *testing.T, path string) *Outbox {
+	t.Helper()
+	if path == "" {
+		path = filepath.Join(t.TempDir(), "outbox.db")
+	}
+	outbox, err := OpenOutbox(&config.Config{Outbox: config.OutboxConfig{Path: path, Retention: time.Hour}})
+	if err != nil {
+		t.Fatalf("Failed to open outbox: %v", err)
+	}
+	t.Cleanup(func() {
+		outbox.Close() //nolint:errcheck
+	})
+	return outbox
+}
+
+func newTestDispatcher(t *testing.T, sender EntrySender, outbox *Outbox, cfg *config.Config) *Dispatcher {
+	t.Helper()
+	if outbox == nil {
+		outbox = newTestOutbox(t, "")
+	}
+	dispatcher, err := NewDispatcher(sender, outbox, cfg)
+	if err != nil {
+		t.Fatalf("Failed to create dispatcher: %v", err)
+	}
+	return dispatcher
+}
+
+func newTestJobs(ids ...int64) []*DeliveryJob {
+	jobs := make([]*DeliveryJob, 0, len(ids))
+	for _, id := range ids {
+		jobs = append(jobs, &DeliveryJob{
+			Destination: &config.Destination{Name: "team"},
+			Feed:        &models.WebhookFeed{ID: 8},
+			Entry:       &models.WebhookEntry{ID: id},
+		})
+	}
+	return jobs
+}
+
+func TestDispatcher_DeliversQueuedJobsOnStop(t *testing.T) {
+	sender := &recordingSender{}
+	cfg := &config.Config{Delivery: config.DeliveryConfig{Workers: 2, QueueSize: 10}}
+	dispatcher := newTestDispatcher(t, sender, nil, cfg)
+	dispatcher.Start()
+
+	if err := dispatcher.Enqueue(newTestJobs(1, 2, 3)); err != nil {
+		t.Fatalf("Failed to enqueue jobs: %v", err)
+	}
+
+	if err := dispatcher.Stop(context.Background()); err != nil {
+		t.Fatalf("Failed to stop dispatcher: %v", err)
+	}
+
+	if len(sender.sent) != 3 {
+		t.Errorf("Expected 3 deliveries, got %d", len(sender.sent))
+	}
+
+	if err := dispatcher.Enqueue(newTestJobs(4)); !errors.Is(err, ErrDispatcherStopped) {
+		t.Errorf("Expected ErrDispatcherStopped after Stop, got %v", err)
+	}
+}
+
+func TestDispatcher_RejectsBatchThatDoesNotFit(t *testing.T) {
+	sender := &recordingSender{release: make(chan struct{})}
+	cfg := &config.Config{Delivery: config.DeliveryConfig{Workers: 1, QueueSize: 2}}
+	dispatcher := newTestDispatcher(t, sender, nil, cfg)
+
+	// Workers are not started yet, so the queue fills up
+	if err := dispatcher.Enqueue(newTestJobs(1)); err != nil {
+		t.Fatalf("Failed to enqueue first job: %v", err)
+	}
+
+	if err := dispatcher.Enqueue(newTestJobs(2, 3)); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Expected ErrQueueFull, got %v", err)
+	}
+
+	// The rejected batch must not be partially queued
+	if err := dispatcher.Enqueue(newTestJobs(4)); err != nil {
+		t.Fatalf("Expected room for one more job, got %v", err)
+	}
+
+	close(sender.release)
+	dispatcher.Start()
+	if err := dispatcher.Stop(context.Background()); err != nil {
+		t.Fatalf("Failed to stop dispatcher: %v", err)
+	}
+
+	if len(sender.sent) != 2 || sender.sent[0] != 1 || sender.sent[1] != 4 {
+		t.Errorf("Expected entries [1 4] to be delivered, got %v", sender.sent)
+	}
+}
+
+func TestDispatcher_StopCancelsInFlightDeliveries(t *testing.T) {
+	sender := &recordingSender{release: make(chan struct{})}
+	cfg := &config.Config{Delivery: config.DeliveryConfig{Workers: 1, QueueSize: 1}}
+	dispatcher := newTestDispatcher(t, sender, nil, cfg)
+	dispatcher.Start()
+
+	if err := dispatcher.Enqueue(newTestJobs(1)); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := dispatcher.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	if len(sender.sent) != 0 {
+		t.Errorf("Expected the blocked delivery to be cancelled, got %v", sender.sent)
+	}
+}
+
+// laneSender blocks deliveries to one destination until released
+type laneSender struct {
+	blocked   string
+	release   chan struct{}
+	delivered chan string
+}
+
+func (l *laneSender) SendEntryToFeishu(ctx context.Context, entry *models.WebhookEntry, feed *models.WebhookFeed, dest *config.Destination) error {
+	if dest.Name == l.blocked {
+		select {
+		case <-l.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	l.delivered <- dest.Name
+	return nil
+}
+
+func TestDispatcher_DestinationsDoNotBlockEachOther(t *testing.T) {
+	sender := &laneSender{blocked: "slow", release: make(chan struct{}), delivered: make(chan string, 4)}
+	cfg := &config.Config{Delivery: config.DeliveryConfig{Workers: 1, QueueSize: 10}}
+	dispatcher := newTestDispatcher(t, sender, nil, cfg)
+	dispatcher.Start()
+
+	jobs := newTestJobs(1, 2, 3)
+	jobs[0].Destination = &config.Destination{Name: "slow"}
+	jobs[1].Destination = jobs[0].Destination
+	if err := dispatcher.Enqueue(jobs); err != nil {
+		t.Fatalf("Failed to enqueue jobs: %v", err)
+	}
+
+	select {
+	case name := <-sender.delivered:
+		if name != "team" {
+			t.Errorf("Expected the entry for team to be delivered first, got %s", name)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected team to be delivered while slow is blocked")
+	}
+
+	close(sender.release)
+	if err := dispatcher.Stop(context.Background()); err != nil {
+		t.Fatalf("Failed to stop dispatcher: %v", err)
+	}
+	if len(sender.delivered) != 2 {
+		t.Errorf("Expected the 2 entries for slow to be delivered after release, got %d", len(sender.delivered))
+	}
+}
+
+func TestDispatcher_FullQueueOnlyRejectsItsDestination(t *testing.T) {
+	sender := &recordingSender{}
+	cfg := &config.Config{Delivery: config.DeliveryConfig{Workers: 1, QueueSize: 2}}
+	dispatcher := newTestDispatcher(t, sender, nil, cfg)
+
+	// Workers are not started yet, so the lane of team fills up
+	if err := dispatcher.Enqueue(newTestJobs(1, 2)); err != nil {
+		t.Fatalf("Failed to enqueue jobs: %v", err)
+	}
+
+	if err := dispatcher.Enqueue(newTestJobs(3)); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Expected ErrQueueFull for team, got %v", err)
+	}
+
+	other := newTestJobs(4)
+	other[0].Destination = &config.Destination{Name: "other"}
+	if err := dispatcher.Enqueue(other); err != nil {
+		t.Fatalf("Expected other to be queued, got %v", err)
+	}
+
+	// A fan-out queues the destination with room and reports the full one
+	fanout := newTestJobs(5, 6)
+	fanout[1].Destination = other[0].Destination
+	err := dispatcher.Enqueue(fanout)
+	var full *QueueFullError
+	if !errors.As(err, &full) || len(full.Destinations) != 1 || full.Destinations[0] != "team" {
+		t.Fatalf("Expected a QueueFullError for team, got %v", err)
+	}
+
+	dispatcher.Start()
+	if err := dispatcher.Stop(context.Background()); err != nil {
+		t.Fatalf("Failed to stop dispatcher: %v", err)
+	}
+	slices.Sort(sender.sent)
+	if !slices.Equal(sender.sent, []int64{1, 2, 4, 6}) {
+		t.Errorf("Expected entries [1 2 4 6] to be delivered, got %v", sender.sent)
+	}
+}
+
+func TestDispatcher_ResumesPendingJobsAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.db")
+	cfg := &config.Config{
+		Delivery:     config.DeliveryConfig{Workers: 1, QueueSize: 10},
+		Destinations: map[string]*config.Destination{"team": {Name: "team"}},
+	}
+
+	// The first run is interrupted before its only worker can send anything
+	outbox := newTestOutbox(t, path)
+	blocked := &recordingSender{release: make(chan struct{})}
+	first := newTestDispatcher(t, blocked, outbox, cfg)
+	first.Start()
+	if err := first.Enqueue(newTestJobs(1, 2)); err != nil {
+		t.Fatalf("Failed to enqueue jobs: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := first.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if err := outbox.Close(); err != nil {
+		t.Fatalf("Failed to close outbox: %v", err)
+	}
+
+	sender := &recordingSender{}
+	second := newTestDispatcher(t, sender, newTestOutbox(t, path), cfg)
+	second.Start()
+	if err := second.Stop(context.Background()); err != nil {
+		t.Fatalf("Failed to stop dispatcher: %v", err)
+	}
+
+	if len(sender.sent) != 2 || sender.sent[0] != 1 || sender.sent[1] != 2 {
+		t.Errorf("Expected entries [1 2] to be resumed, got %v", sender.sent)
+	}
+}
+
+func TestDispatcher_LegacyWebhookURLIsNotStored(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.db")
+	cfg := &config.Config{Delivery: config.DeliveryConfig{Workers: 1, QueueSize: 10}}
+	newLegacyJob := func(id int64) *DeliveryJob {
+		return &DeliveryJob{
+			Destination: &config.Destination{Name: "legacy", WebhookURL: "https://open.feishu.cn/open-apis/bot/v2/hook/secret-token", Legacy: true},
+			Feed:        &models.WebhookFeed{ID: 8},
+			Entry:       &models.WebhookEntry{ID: id},
+		}
+	}
+
+	// The first run is interrupted before its only worker can send anything
+	outbox := newTestOutbox(t, path)
+	first := newTestDispatcher(t, &recordingSender{release: make(chan struct{})}, outbox, cfg)
+	first.Start()
+	if err := first.Enqueue([]*DeliveryJob{newLegacyJob(1)}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := first.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if err := outbox.Close(); err != nil {
+		t.Fatalf("Failed to close outbox: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read outbox: %v", err)
+	}
+	if bytes.Contains(data, []byte("secret-token")) {
+		t.Error("Expected the webhook token not to be stored in the outbox")
+	}
+
+	// The URL is unknown after the restart, so the job cannot be resumed
+	outbox = newTestOutbox(t, path)
+	sender := &recordingSender{}
+	second := newTestDispatcher(t, sender, outbox, cfg)
+	second.Start()
+	dead, err := outbox.DeadLetters(DeadLetterFilter{})
+	if err != nil {
+		t.Fatalf("Failed to list dead letters: %v", err)
+	}
+	if len(dead) != 1 || dead[0].Entry.ID != 1 {
+		t.Fatalf("Expected entry 1 in the dead-letter store, got %v", dead)
+	}
+	if _, err := second.Replay([]uint64{dead[0].ID}); !errors.Is(err, ErrUnknownDestination) {
+		t.Errorf("Expected ErrUnknownDestination, got %v", err)
+	}
+
+	// Once Miniflux sends to the same URL again, the dead letter can be replayed
+	if err := second.Enqueue([]*DeliveryJob{newLegacyJob(2)}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	if replayed, err := second.Replay([]uint64{dead[0].ID}); err != nil || replayed != 1 {
+		t.Fatalf("Expected the dead letter to be replayed, got %d, %v", replayed, err)
+	}
+	if err := second.Stop(context.Background()); err != nil {
+		t.Fatalf("Failed to stop dispatcher: %v", err)
+	}
+	slices.Sort(sender.sent)
+	if !slices.Equal(sender.sent, []int64{1, 2}) {
+		t.Errorf("Expected entries [1 2] to be sent, got %v", sender.sent)
+	}
+}
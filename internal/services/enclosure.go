@@ -0,0 +1,113 @@
+package services
+
+import (
+	"strings"
+
+	"miniflux-feishu/internal/i18n"
+	"miniflux-feishu/internal/models"
+	"miniflux-feishu/internal/textutil"
+)
+
+// enclosure is an entry enclosure prepared for display.
+type enclosure struct {
+	Icon string
+	// Action is the label of the link, "Play" for audio and video.
+	Action string
+	URL    string
+	// Details lists the MIME type and size when they are known.
+	Details string
+}
+
+// text renders the enclosure for plain text messages.
+func (e enclosure) text() string {
+	if e.Details == "" {
+		return e.Icon + " " + e.Action + ": " + e.URL
+	}
+	return e.Icon + " " + e.Details + "\n" + e.Action + ": " + e.URL
+}
+
+// markdown renders the enclosure as a lark_md line for cards.
+func (e enclosure) markdown() string {
+	line := e.Icon + " " + textutil.MarkdownLink(e.Action, e.URL)
+	if e.Details != "" {
+		line += " " + textutil.EscapeMarkdown(e.Details)
+	}
+	return line
+}
+
+// postParagraph renders the enclosure as a post paragraph.
+func (e enclosure) postParagraph() []FeishuPostElement {
+	prefix := e.Icon + " "
+	if e.Details != "" {
+		prefix += e.Details + " "
+	}
+	return []FeishuPostElement{
+		{Tag: "text", Text: prefix},
+		{Tag: "a", Text: e.Action, Href: e.URL},
+	}
+}
+
+// enclosureIcons maps the top-level MIME type to the icon shown before it.
+var enclosureIcons = map[string]string{
+	"audio": "🎧",
+	"video": "🎬",
+	"image": "🖼️",
+}
+
+// describeEnclosures returns the enclosures that have a link Feishu can open.
+func describeEnclosures(enclosures []models.WebhookEnclosure, locale *i18n.Locale) []enclosure {
+	var result []enclosure
+	for _, e := range enclosures {
+		link := textutil.LinkURL(e.URL)
+		if link == "" {
+			continue
+		}
+
+		kind := mediaKind(e.MimeType)
+		icon, ok := enclosureIcons[kind]
+		if !ok {
+			icon = "📎"
+		}
+		action := locale.T(i18n.Download)
+		if kind == "audio" || kind == "video" {
+			action = locale.T(i18n.Play)
+		}
+
+		var details []string
+		if e.MimeType != "" {
+			details = append(details, e.MimeType)
+		}
+		if e.Size > 0 {
+			details = append(details, textutil.HumanSize(e.Size))
+		}
+
+		result = append(result, enclosure{
+			Icon:    icon,
+			Action:  action,
+			URL:     link,
+			Details: strings.Join(details, " · "),
+		})
+	}
+	return result
+}
+
+// mediaKind returns the top-level type of a MIME type, such as "audio".
+func mediaKind(mimeType string) string {
+	kind, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(mimeType)), "/")
+	return kind
+}
+
+// hasEnclosure reports whether the entry has an enclosure of one of the given
+// types. A type without a slash, such as "audio", matches all its subtypes.
+func hasEnclosure(entry *models.WebhookEntry, types []string) bool {
+	for _, e := range entry.Enclosures {
+		mimeType := strings.ToLower(strings.TrimSpace(e.MimeType))
+		for _, t := range types {
+			t = strings.ToLower(t)
+			if mimeType == t || (!strings.Contains(t, "/") && mediaKind(mimeType) == t) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"slices"
+	"testing"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/i18n"
+	"miniflux-feishu/internal/models"
+)
+
+func TestDescribeEnclosures(t *testing.T) {
+	enclosures := []models.WebhookEnclosure{
+		{URL: "https://example.org/episode.mp3", MimeType: "audio/mpeg", Size: 63451045},
+		{URL: "https://example.org/talk.mp4", MimeType: "video/mp4"},
+		{URL: "https://example.org/slides.pdf", MimeType: "application/pdf", Size: 2048},
+		{URL: "javascript:alert(1)", MimeType: "audio/mpeg"},
+		{URL: "", MimeType: "image/png"},
+	}
+
+	expected := []enclosure{
+		{Icon: "🎧", Action: "Play", URL: "https://example.org/episode.mp3", Details: "audio/mpeg · 60.5 MB"},
+		{Icon: "🎬", Action: "Play", URL: "https://example.org/talk.mp4", Details: "video/mp4"},
+		{Icon: "📎", Action: "Download", URL: "https://example.org/slides.pdf", Details: "application/pdf · 2.0 KB"},
+	}
+	english, _ := i18n.Lookup("en-US")
+	if result := describeEnclosures(enclosures, english); !slices.Equal(result, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result)
+	}
+
+	chinese, _ := i18n.Lookup("zh-CN")
+	if result := describeEnclosures(enclosures[:1], chinese); len(result) != 1 || result[0].Action != "播放" {
+		t.Errorf("Expected a localized action, got %+v", result)
+	}
+
+	if result := expected[0].markdown(); result != "🎧 [Play](https://example.org/episode.mp3) audio/mpeg · 60.5 MB" {
+		t.Errorf("Unexpected markdown %q", result)
+	}
+	parens := enclosure{Icon: "🎧", Action: "Play", URL: "https://cdn.example.org/show_(live).mp3"}
+	if result := parens.markdown(); result != "🎧 [Play](https://cdn.example.org/show_%28live%29.mp3)" {
+		t.Errorf("Expected parentheses in the URL to be escaped, got %q", result)
+	}
+	if result := expected[1].text(); result != "🎬 video/mp4\nPlay: https://example.org/talk.mp4" {
+		t.Errorf("Unexpected text %q", result)
+	}
+}
+
+func TestMatchesFilter(t *testing.T) {
+	podcast := &models.WebhookEntry{Enclosures: []models.WebhookEnclosure{{URL: "https://example.org/1.mp3", MimeType: "Audio/MPEG"}}}
+	article := &models.WebhookEntry{}
+
+	tests := []struct {
+		name     string
+		filter   config.FilterConfig
+		entry    *models.WebhookEntry
+		expected bool
+	}{
+		{name: "empty filter", entry: article, expected: true},
+		{name: "audio type", filter: config.FilterConfig{EnclosureTypes: []string{"audio"}}, entry: podcast, expected: true},
+		{name: "exact MIME type", filter: config.FilterConfig{EnclosureTypes: []string{"audio/mpeg"}}, entry: podcast, expected: true},
+		{name: "other MIME type", filter: config.FilterConfig{EnclosureTypes: []string{"audio/ogg"}}, entry: podcast, expected: false},
+		{name: "video only", filter: config.FilterConfig{EnclosureTypes: []string{"video"}}, entry: podcast, expected: false},
+		{name: "no enclosures", filter: config.FilterConfig{EnclosureTypes: []string{"audio"}}, entry: article, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := MatchesFilter(tt.filter, tt.entry, &models.WebhookFeed{}); result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
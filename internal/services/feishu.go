@@ -2,52 +2,200 @@ package services
 
 import (
 	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/i18n"
 	"miniflux-feishu/internal/models"
+	"miniflux-feishu/internal/textutil"
 )
 
 type FeishuService struct {
-	client *http.Client
+	client    *http.Client
+	security  config.SecurityConfig
+	now       func() time.Time
+	sleep     func(ctx context.Context, d time.Duration) error
+	limiter   *rateLimiter
+	templates *templateCache
+	// images uploads lead images, nil without app credentials
+	images *imageUploader
+	// locale is the default locale of destinations that set none
+	locale   string
+	mentions *mentionLimiter
 }
 
 type FeishuMessage struct {
-	MsgType string             `json:"msg_type"`
-	Content FeishuTextContent  `json:"content"`
+	Timestamp string            `json:"timestamp,omitempty"`
+	Sign      string            `json:"sign,omitempty"`
+	MsgType   string            `json:"msg_type"`
+	Content   FeishuTextContent `json:"content,omitzero"`
+	Card      *FeishuCard       `json:"card,omitempty"`
+	// Rendered is the output of a destination template. It is sent as is in
+	// place of Content, or of Card for interactive messages.
+	Rendered json.RawMessage `json:"-"`
 }
 
+// feishuResponse is the body Feishu returns for bot webhook calls.
+type feishuResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// maxResponseBodySize bounds how much of a Feishu response is read.
+const maxResponseBodySize = 64 << 10
+
 type FeishuTextContent struct {
 	Title   string `json:"title"`
 	Content string `json:"content"`
 	URL     string `json:"url"`
+	// Post is set for "post" messages and replaces the fields above.
+	Post FeishuPost `json:"post,omitempty"`
 }
 
-func NewFeishuService() *FeishuService {
-	return &FeishuService{
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+func NewFeishuService(cfg *config.Config) *FeishuService {
+	s := &FeishuService{
+		security:  cfg.Security,
+		now:       time.Now,
+		sleep:     sleepContext,
+		templates: newTemplateCache(cfg),
+		locale:    cfg.Locale,
+		mentions:  newMentionLimiter(),
 	}
+	s.client = newGuardedClient(cfg.Security, s.ValidateWebhookURL)
+	if cfg.App.Configured() {
+		s.images = newImageUploader(cfg, s.client, newGuardedClient(cfg.Security, s.validateImageURL), func() time.Time { return s.now() })
+	}
+	s.limiter = newRateLimiter(
+		func() time.Time { return s.now() },
+		func(ctx context.Context, d time.Duration) error { return s.sleep(ctx, d) },
+	)
+	return s
 }
 
-func (s *FeishuService) SendEntryToFeishu(entry *models.WebhookEntry, feed *models.WebhookFeed, webhookURL string) error {
-	message := s.formatEntryMessage(entry, feed)
-	return s.sendMessage(message, webhookURL)
+// SendEntryToFeishu formats the entry and sends it to the destination,
+// retrying transient failures according to the destination's retry policy.
+func (s *FeishuService) SendEntryToFeishu(ctx context.Context, entry *models.WebhookEntry, feed *models.WebhookFeed, dest *config.Destination) error {
+	message, err := s.RenderMessage(entry, feed, dest)
+	if err != nil {
+		deliveryMetrics.Add("failed", 1)
+		return fmt.Errorf("failed to render entry %d: %w", entry.ID, err)
+	}
+	s.attachLeadImage(ctx, &message, entry, dest)
+	mentioned := s.addMentions(&message, entry, feed, dest)
+	policy := dest.Retry.WithDefaults(config.DefaultRetryPolicy)
+
+	// Mentions only count against the daily limit once they were delivered
+	sent := false
+	defer func() {
+		if !sent {
+			s.mentions.refund(mentioned)
+		}
+	}()
+
+	var attempts []DeliveryAttempt
+	for attempt := 1; ; attempt++ {
+		waited, err := s.limiter.Wait(ctx, dest)
+		if err != nil {
+			deliveryMetrics.Add("failed", 1)
+			return fmt.Errorf("rate limit wait for entry %d cancelled: %w", entry.ID, err)
+		}
+		if waited > 0 {
+			rateLimitWaitMetrics.AddFloat(dest.Name, waited.Seconds())
+		}
+
+		// Signatures expire, so sign every attempt again
+		if dest.Secret != "" {
+			message.Timestamp, message.Sign = s.signMessage(dest.Secret)
+		}
+
+		err = s.sendMessage(ctx, message, dest.WebhookURL)
+		if err == nil {
+			sent = true
+			deliveryMetrics.Add("sent", 1)
+			if mentioned != nil {
+				mentionMetrics.Add("sent", 1)
+			}
+			return nil
+		}
+		sendErrorMetrics.Add(ErrorKind(err), 1)
+		attempts = append(attempts, newDeliveryAttempt(s.now(), err))
+		if !isRetryable(err) {
+			deliveryMetrics.Add("failed", 1)
+			return &DeliveryError{Attempts: attempts, Err: err}
+		}
+		if attempt >= policy.MaxAttempts {
+			deliveryMetrics.Add("failed", 1)
+			return &DeliveryError{Attempts: attempts, Err: fmt.Errorf("giving up after %d attempts: %w", attempt, err)}
+		}
+
+		// Retry-After is honoured up to MaxBackoff, so a bogus value cannot
+		// hold a worker for hours
+		delay := backoff(policy, attempt)
+		var sendErr *SendError
+		if errors.As(err, &sendErr) && sendErr.RetryAfter > delay {
+			delay = min(sendErr.RetryAfter, policy.MaxBackoff)
+		}
+		log.Printf("Attempt %d/%d to send entry %d to %s failed, retrying in %s: %v", attempt, policy.MaxAttempts, entry.ID, dest.Name, delay, err)
+
+		if err := s.sleep(ctx, delay); err != nil {
+			deliveryMetrics.Add("failed", 1)
+			return fmt.Errorf("retry of entry %d cancelled: %w", entry.ID, err)
+		}
+	}
 }
 
-func (s *FeishuService) formatEntryMessage(entry *models.WebhookEntry, feed *models.WebhookFeed) FeishuMessage {
-	content := ""
-	if entry.Content != "" {
-		content = s.stripHTML(entry.Content)
-		if len(content) > 300 {
-			content = content[:300] + "..."
+// RenderMessage builds the message sent for the entry, using the
+// destination's template if it has one and its format's layout otherwise.
+func (s *FeishuService) RenderMessage(entry *models.WebhookEntry, feed *models.WebhookFeed, dest *config.Destination) (FeishuMessage, error) {
+	if dest.Template != "" {
+		return s.formatTemplate(entry, feed, dest)
+	}
+	switch dest.Format {
+	case config.FormatCard:
+		return s.formatEntryCard(entry, feed, dest), nil
+	case config.FormatPost:
+		return s.formatEntryPost(entry, feed, dest), nil
+	default:
+		return s.formatEntryMessage(entry, feed, dest), nil
+	}
+}
+
+func (s *FeishuService) formatEntryMessage(entry *models.WebhookEntry, feed *models.WebhookFeed, dest *config.Destination) FeishuMessage {
+	var metadata entryMetadata
+	var sections []string
+	if dest.Metadata {
+		metadata = s.entryMetadata(entry, dest)
+		if header := metadata.header(); header != "" {
+			sections = append(sections, header)
 		}
 	}
+	if summary := s.summarize(entry.Content, dest); summary != "" {
+		sections = append(sections, summary)
+	}
+	if enclosures := describeEnclosures(entry.Enclosures, s.localeFor(dest)); len(enclosures) > 0 {
+		lines := make([]string, len(enclosures))
+		for i, e := range enclosures {
+			lines[i] = e.text()
+		}
+		sections = append(sections, strings.Join(lines, "\n"))
+	}
+	if metadata.CommentsURL != "" {
+		sections = append(sections, s.localeFor(dest).T(i18n.Comments)+": "+metadata.CommentsURL)
+	}
+	content := strings.Join(sections, "\n\n")
 
 	title := fmt.Sprintf("[%s] - %s", feed.Title, entry.Title)
 
@@ -61,38 +209,56 @@ func (s *FeishuService) formatEntryMessage(entry *models.WebhookEntry, feed *mod
 	}
 }
 
-func (s *FeishuService) stripHTML(html string) string {
-	result := html
-	result = strings.ReplaceAll(result, "<br>", "\n")
-	result = strings.ReplaceAll(result, "<br/>", "\n")
-	result = strings.ReplaceAll(result, "<br />", "\n")
-	result = strings.ReplaceAll(result, "<p>", "\n")
-	result = strings.ReplaceAll(result, "</p>", "")
-
-	inTag := false
-	var cleaned strings.Builder
-	for _, char := range result {
-		if char == '<' {
-			inTag = true
-		} else if char == '>' {
-			inTag = false
-		} else if !inTag {
-			cleaned.WriteRune(char)
-		}
+// localeFor returns the locale of the destination's message labels.
+func (s *FeishuService) localeFor(dest *config.Destination) *i18n.Locale {
+	tag := dest.Locale
+	if tag == "" {
+		tag = s.locale
 	}
+	locale, _ := i18n.Lookup(tag)
+	return locale
+}
+
+// summarize turns the entry HTML into a plain text excerpt, followed by the
+// footnotes of the links it still contains when the destination wants them.
+func (s *FeishuService) summarize(html string, dest *config.Destination) string {
+	if html == "" {
+		return ""
+	}
+	text := textutil.ConvertHTML(html, textutil.TextOptions{LinkFootnotes: dest.LinkFootnotes})
+	summary := dest.Summary.WithDefaults(config.DefaultSummary)
+	content := textutil.TruncateText(text.Body, textutil.TruncateOptions{
+		Length:       summary.Length,
+		Ellipsis:     summary.Ellipsis,
+		DisplayWidth: summary.DisplayWidth,
+	})
+
+	referenced := 0
+	for referenced < len(text.Links) && strings.Contains(content, "["+strconv.Itoa(referenced+1)+"]") {
+		referenced++
+	}
+	return content + text.Footnotes(referenced)
+}
 
-	text := cleaned.String()
-	text = strings.ReplaceAll(text, "\n\n", "\n")
-	return strings.TrimSpace(text)
+// signMessage computes the timestamp and signature required by the custom bot
+// "签名校验" setting: base64(HMAC-SHA256(key = timestamp + "\n" + secret, message = "")).
+func (s *FeishuService) signMessage(secret string) (string, string) {
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(timestamp+"\n"+secret))
+	return timestamp, base64.StdEncoding.EncodeToString(mac.Sum(nil))
 }
 
-func (s *FeishuService) sendMessage(message FeishuMessage, webhookURL string) error {
+func (s *FeishuService) sendMessage(ctx context.Context, message FeishuMessage, webhookURL string) error {
+	if err := s.ValidateWebhookURL(webhookURL); err != nil {
+		return err
+	}
+
 	payload, err := json.Marshal(message)
 	if err != nil {
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", webhookURL, bytes.NewBuffer(payload))
+	req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, bytes.NewBuffer(payload))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -102,7 +268,15 @@ func (s *FeishuService) sendMessage(message FeishuMessage, webhookURL string) er
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		// The bot token is part of the URL, keep it out of logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = redactWebhookURL(urlErr.URL)
+		}
+		return &SendError{
+			Retryable: !errors.Is(err, ErrWebhookURLNotAllowed),
+			Err:       err,
+		}
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
@@ -110,13 +284,40 @@ func (s *FeishuService) sendMessage(message FeishuMessage, webhookURL string) er
 		}
 	}()
 
-	if resp.StatusCode != http.StatusOK {
-		var respBody bytes.Buffer
-		if _, err := respBody.ReadFrom(resp.Body); err != nil {
-			return fmt.Errorf("failed to read response body: %w", err)
+	// Custom bots report most failures as HTTP 200 with a non-zero code, so the
+	// body is checked on every call
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodySize))
+	if err != nil {
+		if resp.StatusCode == http.StatusOK {
+			log.Printf("failed to read response body, assuming success: %v", err)
+			return nil
 		}
-		return fmt.Errorf("feishu API returned status %d: %s", resp.StatusCode, respBody.String())
+		return &SendError{StatusCode: resp.StatusCode, Retryable: true, Err: fmt.Errorf("failed to read response body: %w", err)}
+	}
+
+	var body feishuResponse
+	decoded := json.Unmarshal(respBody, &body) == nil
+	if resp.StatusCode == http.StatusOK && (!decoded || body.Code == 0) {
+		return nil
 	}
 
-	return nil
+	code, msg := 0, string(respBody)
+	if decoded && body.Code != 0 {
+		code, msg = body.Code, body.Msg
+	}
+	return newResponseError(resp.StatusCode, code, msg, parseRetryAfter(resp.Header.Get("Retry-After"), s.now()))
+}
+
+// redactWebhookURL hides the last path segment of a webhook URL, which holds
+// the bot token for Feishu custom bots.
+func redactWebhookURL(webhookURL string) string {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return "[invalid url]"
+	}
+	path := u.Path
+	if i := strings.LastIndex(path, "/"); i >= 0 && i < len(path)-1 {
+		path = path[:i+1] + "***"
+	}
+	return u.Scheme + "://" + u.Host + path
 }
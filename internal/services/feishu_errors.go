@@ -0,0 +1,156 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Errors reported by Feishu, matched with errors.Is against the error returned
+// by SendEntryToFeishu.
+var (
+	ErrRateLimited      = errors.New("feishu: rate limited")
+	ErrKeywordMismatch  = errors.New("feishu: message does not contain the bot's keywords")
+	ErrSignatureInvalid = errors.New("feishu: signature invalid or timestamp expired")
+	ErrBotRemoved       = errors.New("feishu: bot removed or disabled")
+	ErrPayloadTooLarge  = errors.New("feishu: payload too large")
+	ErrInvalidWebhook   = errors.New("feishu: invalid webhook")
+)
+
+// Feishu error codes returned in the response body.
+const (
+	feishuCodeBadRequest      = 9499   // also used for "too many request"
+	feishuCodeFrequencyLimit  = 11232  // frequency limited
+	feishuCodeInvalidWebhook  = 19001  // incoming webhook access token invalid
+	feishuCodeBotNotEnabled   = 19007  // bot removed or disabled
+	feishuCodeSignatureFailed = 19021  // sign match fail or timestamp is not within one hour
+	feishuCodeKeywordMismatch = 19024  // Key Words Not Found
+	feishuCodeBotNotInChat    = 230002 // bot is no longer in the chat
+	feishuCodeContentTooLong  = 230025 // message content exceeds the length limit
+)
+
+// SendError describes a failed attempt to deliver a message to Feishu.
+type SendError struct {
+	// StatusCode is the HTTP status, or 0 when no response was received.
+	StatusCode int
+	// Code and Msg come from the Feishu response body, if it had one.
+	Code int
+	Msg  string
+	// Kind is one of the Err* values above, or nil for other failures.
+	Kind error
+	// RetryAfter is taken from the Retry-After response header.
+	RetryAfter time.Duration
+	// Retryable is false for failures that another attempt cannot fix.
+	Retryable bool
+	Err       error
+}
+
+func (e *SendError) Error() string {
+	switch {
+	case e.StatusCode == 0:
+		return fmt.Sprintf("failed to send request: %v", e.Err)
+	case e.Code != 0:
+		return fmt.Sprintf("feishu API returned status %d, code %d: %s", e.StatusCode, e.Code, e.Msg)
+	default:
+		return fmt.Sprintf("feishu API returned status %d: %s", e.StatusCode, e.Msg)
+	}
+}
+
+func (e *SendError) Unwrap() []error {
+	var errs []error
+	if e.Kind != nil {
+		errs = append(errs, e.Kind)
+	}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
+
+// newResponseError builds the error for a Feishu response that reported a
+// failure, either through the HTTP status or the code in the body.
+func newResponseError(statusCode, code int, msg string, retryAfter time.Duration) *SendError {
+	kind := classifyFeishuError(statusCode, code, msg)
+	return &SendError{
+		StatusCode: statusCode,
+		Code:       code,
+		Msg:        msg,
+		Kind:       kind,
+		RetryAfter: retryAfter,
+		// Rate limiting and server-side failures are retried, anything else
+		// will fail the same way again
+		Retryable: kind == ErrRateLimited || (kind == nil && statusCode >= 500),
+	}
+}
+
+func classifyFeishuError(statusCode, code int, msg string) error {
+	lowerMsg := strings.ToLower(msg)
+	switch {
+	case statusCode == http.StatusTooManyRequests,
+		code == feishuCodeFrequencyLimit,
+		code == feishuCodeBadRequest && strings.Contains(lowerMsg, "too many request"):
+		return ErrRateLimited
+	case code == feishuCodeKeywordMismatch:
+		return ErrKeywordMismatch
+	case code == feishuCodeSignatureFailed:
+		return ErrSignatureInvalid
+	case code == feishuCodeBotNotEnabled, code == feishuCodeBotNotInChat:
+		return ErrBotRemoved
+	case statusCode == http.StatusRequestEntityTooLarge,
+		code == feishuCodeContentTooLong,
+		code == feishuCodeBadRequest && strings.Contains(lowerMsg, "too large"):
+		return ErrPayloadTooLarge
+	case code == feishuCodeInvalidWebhook, statusCode == http.StatusNotFound:
+		return ErrInvalidWebhook
+	default:
+		return nil
+	}
+}
+
+// isRetryable reports whether another attempt could succeed.
+func isRetryable(err error) bool {
+	var sendErr *SendError
+	if errors.As(err, &sendErr) {
+		return sendErr.Retryable
+	}
+	return false
+}
+
+// ErrorKind returns a short, stable label for err, suitable for metrics.
+func ErrorKind(err error) string {
+	switch {
+	case err == nil:
+		return "none"
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, ErrKeywordMismatch):
+		return "keyword_mismatch"
+	case errors.Is(err, ErrSignatureInvalid):
+		return "signature_invalid"
+	case errors.Is(err, ErrBotRemoved):
+		return "bot_removed"
+	case errors.Is(err, ErrPayloadTooLarge):
+		return "payload_too_large"
+	case errors.Is(err, ErrInvalidWebhook):
+		return "invalid_webhook"
+	case errors.Is(err, ErrWebhookURLNotAllowed):
+		return "url_not_allowed"
+	}
+
+	var sendErr *SendError
+	if errors.As(err, &sendErr) {
+		switch {
+		case sendErr.StatusCode == 0:
+			return "network"
+		case sendErr.StatusCode >= 500:
+			return "server_error"
+		case sendErr.StatusCode != http.StatusOK:
+			return "client_error"
+		default:
+			return "api_error"
+		}
+	}
+	return "other"
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"errors"
+	"expvar"
+	"net/http"
+	"testing"
+
+	"miniflux-feishu/internal/config"
+)
+
+func TestFeishuService_SendEntryToFeishu_ResponseErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		response     scriptedResponse
+		expectedErr  error
+		expectedKind string
+	}{
+		{
+			name:         "keyword mismatch with HTTP 200",
+			response:     scriptedResponse{status: http.StatusOK, body: `{"code":19024,"msg":"Key Words Not Found"}`},
+			expectedErr:  ErrKeywordMismatch,
+			expectedKind: "keyword_mismatch",
+		},
+		{
+			name:         "signature invalid",
+			response:     scriptedResponse{status: http.StatusOK, body: `{"code":19021,"msg":"sign match fail or timestamp is not within one hour from current time"}`},
+			expectedErr:  ErrSignatureInvalid,
+			expectedKind: "signature_invalid",
+		},
+		{
+			name:         "bot removed",
+			response:     scriptedResponse{status: http.StatusOK, body: `{"code":19007,"msg":"Bot Not Enabled"}`},
+			expectedErr:  ErrBotRemoved,
+			expectedKind: "bot_removed",
+		},
+		{
+			name:         "payload too large",
+			response:     scriptedResponse{status: http.StatusRequestEntityTooLarge, body: "request entity too large"},
+			expectedErr:  ErrPayloadTooLarge,
+			expectedKind: "payload_too_large",
+		},
+		{
+			name:         "invalid webhook",
+			response:     scriptedResponse{status: http.StatusOK, body: `{"code":19001,"msg":"param invalid: incoming webhook access token invalid"}`},
+			expectedErr:  ErrInvalidWebhook,
+			expectedKind: "invalid_webhook",
+		},
+		{
+			name:         "rate limited with HTTP 200",
+			response:     scriptedResponse{status: http.StatusOK, body: `{"code":9499,"msg":"too many request"}`},
+			expectedErr:  ErrRateLimited,
+			expectedKind: "rate_limited",
+		},
+		{
+			name:         "unknown code",
+			response:     scriptedResponse{status: http.StatusOK, body: `{"code":12345,"msg":"something else"}`},
+			expectedKind: "api_error",
+		},
+	}
+
+	// Single attempt so each case produces exactly one error
+	policy := config.RetryPolicy{MaxAttempts: 1}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := newScriptedServer(t, tt.response)
+			service := NewFeishuService(testConfig())
+
+			before := expvarCount(sendErrorMetrics, tt.expectedKind)
+			err := sendTestEntry(service, server.URL, policy)
+			if err == nil {
+				t.Fatalf("Expected an error, got nil")
+			}
+
+			if tt.expectedErr != nil && !errors.Is(err, tt.expectedErr) {
+				t.Errorf("Expected errors.Is(err, %v), got %v", tt.expectedErr, err)
+			}
+
+			if kind := ErrorKind(err); kind != tt.expectedKind {
+				t.Errorf("Expected kind %q, got %q", tt.expectedKind, kind)
+			}
+
+			if after := expvarCount(sendErrorMetrics, tt.expectedKind); after != before+1 {
+				t.Errorf("Expected feishu_send_errors[%s] to increase by 1, got %d -> %d", tt.expectedKind, before, after)
+			}
+		})
+	}
+}
+
+func TestFeishuService_SendEntryToFeishu_SuccessResponses(t *testing.T) {
+	for _, body := range []string{
+		`{"code":0,"msg":"success","data":{}}`,
+		`{"Extra":null,"StatusCode":0,"StatusMessage":"success"}`,
+		`ok`,
+		``,
+	} {
+		server, _ := newScriptedServer(t, scriptedResponse{status: http.StatusOK, body: body})
+		service := NewFeishuService(testConfig())
+
+		before := expvarCount(deliveryMetrics, "sent")
+		if err := sendTestEntry(service, server.URL, config.RetryPolicy{MaxAttempts: 1}); err != nil {
+			t.Errorf("Expected success for body %q, got %v", body, err)
+		}
+		if after := expvarCount(deliveryMetrics, "sent"); after != before+1 {
+			t.Errorf("Expected feishu_deliveries[sent] to increase by 1, got %d -> %d", before, after)
+		}
+	}
+}
+
+func TestErrorKind(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{err: nil, expected: "none"},
+		{err: &SendError{Err: errors.New("connection refused"), Retryable: true}, expected: "network"},
+		{err: &SendError{StatusCode: http.StatusBadGateway}, expected: "server_error"},
+		{err: &SendError{StatusCode: http.StatusBadRequest}, expected: "client_error"},
+		{err: ErrWebhookURLNotAllowed, expected: "url_not_allowed"},
+		{err: errors.New("boom"), expected: "other"},
+	}
+
+	for _, tt := range tests {
+		if kind := ErrorKind(tt.err); kind != tt.expected {
+			t.Errorf("ErrorKind(%v): expected %q, got %q", tt.err, tt.expected, kind)
+		}
+	}
+}
+
+func expvarCount(m *expvar.Map, key string) int64 {
+	if v, ok := m.Get(key).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
@@ -1,17 +1,33 @@
 package services
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"miniflux-feishu/internal/config"
 	"miniflux-feishu/internal/models"
 )
 
+// testConfig allows requests to the local httptest servers used in tests.
+func testConfig() *config.Config {
+	return &config.Config{
+		Security: config.SecurityConfig{
+			AllowedHosts:         []string{"127.0.0.1"},
+			AllowPrivateNetworks: true,
+		},
+	}
+}
+
 func TestFeishuService_FormatEntryMessage(t *testing.T) {
-	service := NewFeishuService()
+	service := NewFeishuService(testConfig())
 
 	// Test data based on the provided request
 	publishedTime, _ := time.Parse(time.RFC3339, "2023-08-17T19:29:22Z")
@@ -55,14 +71,14 @@ func TestFeishuService_FormatEntryMessage(t *testing.T) {
 		CheckedAt: createdTime,
 	}
 
-	message := service.formatEntryMessage(entry, feed)
+	message := service.formatEntryMessage(entry, feed, &config.Destination{})
 
 	// Expected message structure
 	expectedMessage := FeishuMessage{
 		MsgType: "text",
 		Content: FeishuTextContent{
 			Title:   "[Example website] - Example",
-			Content: "Some HTML content",
+			Content: "Some HTML content\n\n🎧 audio/mpeg · 60.5 MB\nPlay: https://example.org/podcast.mp3",
 			URL:     "https://example.org/article",
 		},
 	}
@@ -113,7 +129,7 @@ func TestFeishuService_SendEntryToFeishu_Integration(t *testing.T) {
 	}))
 	defer server.Close()
 
-	service := NewFeishuService()
+	service := NewFeishuService(testConfig())
 
 	// Test data based on the provided request
 	publishedTime, _ := time.Parse(time.RFC3339, "2023-08-17T19:29:22Z")
@@ -158,7 +174,7 @@ func TestFeishuService_SendEntryToFeishu_Integration(t *testing.T) {
 	}
 
 	// Send the entry to the mock server
-	err := service.SendEntryToFeishu(entry, feed, server.URL)
+	err := service.SendEntryToFeishu(context.Background(), entry, feed, &config.Destination{WebhookURL: server.URL})
 	if err != nil {
 		t.Fatalf("Failed to send entry to Feishu: %v", err)
 	}
@@ -188,8 +204,8 @@ func TestFeishuService_SendEntryToFeishu_Integration(t *testing.T) {
 		t.Errorf("Expected Title '[Example website] - Example', got %s", capturedMessage.Content.Title)
 	}
 
-	if capturedMessage.Content.Content != "Some HTML content" {
-		t.Errorf("Expected Content 'Some HTML content', got %s", capturedMessage.Content.Content)
+	if capturedMessage.Content.Content != "Some HTML content\n\n🎧 audio/mpeg · 60.5 MB\nPlay: https://example.org/podcast.mp3" {
+		t.Errorf("Expected Content with the enclosure, got %s", capturedMessage.Content.Content)
 	}
 
 	if capturedMessage.Content.URL != "https://example.org/article" {
@@ -200,35 +216,130 @@ func TestFeishuService_SendEntryToFeishu_Integration(t *testing.T) {
 	t.Logf("Actual webhook body sent to Feishu:\n%s", string(capturedBody))
 }
 
-func TestFeishuService_StripHTML(t *testing.T) {
-	service := NewFeishuService()
+func TestFeishuService_Summarize(t *testing.T) {
+	service := NewFeishuService(testConfig())
+	dest := &config.Destination{LinkFootnotes: true}
+
+	content := `<p>Read <a href="https://example.org/a">this</a>.</p>`
+	if result := service.summarize(content, dest); result != "Read this [1].\n[1] https://example.org/a" {
+		t.Errorf("Expected footnotes after the summary, got %q", result)
+	}
+
+	// Footnotes of links cut off by truncation are left out
+	long := `<p><a href="https://example.org/a">first</a> ` + strings.Repeat("word ", 80) + `<a href="https://example.org/b">second</a></p>`
+	result := service.summarize(long, dest)
+	if !strings.HasSuffix(result, "...\n[1] https://example.org/a") {
+		t.Errorf("Expected only the first footnote, got %q", result)
+	}
+}
+
+func TestFeishuService_Summarize_Length(t *testing.T) {
+	service := NewFeishuService(testConfig())
+	content := "<p>" + strings.Repeat("飞书", 200) + "</p>"
 
 	tests := []struct {
 		name     string
+		summary  config.SummaryConfig
+		expected string
+	}{
+		{name: "default", expected: strings.Repeat("飞书", 150) + "..."},
+		{name: "custom length and ellipsis", summary: config.SummaryConfig{Length: 10, Ellipsis: "……"}, expected: strings.Repeat("飞书", 5) + "……"},
+		{name: "display width", summary: config.SummaryConfig{Length: 10, DisplayWidth: true}, expected: "飞书飞书飞..."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := service.summarize(content, &config.Destination{Summary: tt.summary})
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestFeishuService_SendEntryToFeishu_Signed(t *testing.T) {
+	var capturedMessage FeishuMessage
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&capturedMessage); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":0,"msg":"success"}`)) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	service := NewFeishuService(testConfig())
+	service.now = func() time.Time { return time.Unix(1599360473, 0) }
+
+	entry := &models.WebhookEntry{ID: 231, Title: "Example", URL: "https://example.org/article"}
+	feed := &models.WebhookFeed{ID: 8, Title: "Example website"}
+	dest := &config.Destination{Name: "team", WebhookURL: server.URL, Secret: "bot-secret"}
+
+	if err := service.SendEntryToFeishu(context.Background(), entry, feed, dest); err != nil {
+		t.Fatalf("Failed to send entry to Feishu: %v", err)
+	}
+
+	if capturedMessage.Timestamp != "1599360473" {
+		t.Errorf("Expected timestamp 1599360473, got %q", capturedMessage.Timestamp)
+	}
+
+	mac := hmac.New(sha256.New, []byte("1599360473\nbot-secret"))
+	expectedSign := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if capturedMessage.Sign != expectedSign {
+		t.Errorf("Expected sign %q, got %q", expectedSign, capturedMessage.Sign)
+	}
+}
+
+func TestFeishuService_SendEntryToFeishu_Unsigned(t *testing.T) {
+	var capturedBody map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&capturedBody); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	service := NewFeishuService(testConfig())
+	entry := &models.WebhookEntry{ID: 231, Title: "Example", URL: "https://example.org/article"}
+	feed := &models.WebhookFeed{ID: 8, Title: "Example website"}
+
+	if err := service.SendEntryToFeishu(context.Background(), entry, feed, &config.Destination{WebhookURL: server.URL}); err != nil {
+		t.Fatalf("Failed to send entry to Feishu: %v", err)
+	}
+
+	if _, ok := capturedBody["timestamp"]; ok {
+		t.Errorf("Expected no timestamp field without a secret, got %v", capturedBody["timestamp"])
+	}
+	if _, ok := capturedBody["sign"]; ok {
+		t.Errorf("Expected no sign field without a secret, got %v", capturedBody["sign"])
+	}
+}
+
+func TestRedactWebhookURL(t *testing.T) {
+	tests := []struct {
 		input    string
 		expected string
 	}{
 		{
-			name:     "simple paragraph",
-			input:    "<p>Some HTML content</p>",
-			expected: "Some HTML content",
+			input:    "https://open.feishu.cn/open-apis/bot/v2/hook/0a1b2c3d-token",
+			expected: "https://open.feishu.cn/open-apis/bot/v2/hook/***",
 		},
 		{
-			name:     "multiple tags",
-			input:    "<p>This is <strong>bold</strong> and <em>italic</em> text</p>",
-			expected: "This is bold and italic text",
+			input:    "https://hooks.example.com/webhook?token=secret",
+			expected: "https://hooks.example.com/***",
 		},
 		{
-			name:     "line breaks",
-			input:    "Line 1<br>Line 2<br/>Line 3<br />Line 4",
-			expected: "Line 1\nLine 2\nLine 3\nLine 4",
+			input:    "https://hooks.example.com/",
+			expected: "https://hooks.example.com/",
 		},
 	}
 
 	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := service.stripHTML(tt.input)
-			if result != tt.expected {
+		t.Run(tt.input, func(t *testing.T) {
+			if result := redactWebhookURL(tt.input); result != tt.expected {
 				t.Errorf("Expected %q, got %q", tt.expected, result)
 			}
 		})
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"log"
+	"regexp"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/models"
+	"miniflux-feishu/internal/textutil"
+)
+
+// MatchesFilter reports whether the entry should be forwarded to a
+// destination with the given filter. Keywords, patterns and the expression
+// are only applied once the filter has been compiled. Entries the expression
+// fails on are not forwarded.
+func MatchesFilter(filter config.FilterConfig, entry *models.WebhookEntry, feed *models.WebhookFeed) bool {
+	matched, err := EvaluateFilter(filter, entry, feed)
+	if err != nil {
+		log.Printf("Filter expression failed for entry %d: %v", entry.ID, err)
+	}
+	return matched
+}
+
+// EvaluateFilter is MatchesFilter returning the expression's error, for
+// previews. The entry does not match when the expression fails.
+func EvaluateFilter(filter config.FilterConfig, entry *models.WebhookEntry, feed *models.WebhookFeed) (bool, error) {
+	if len(filter.EnclosureTypes) > 0 && !hasEnclosure(entry, filter.EnclosureTypes) {
+		return false, nil
+	}
+	if filter.Program != nil {
+		matched, err := filter.Program.Match(entry, feed)
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+	if len(filter.IncludeRegexps) == 0 && len(filter.ExcludeRegexps) == 0 {
+		return true, nil
+	}
+
+	texts := filterTexts(filter.Fields, entry)
+	if matchesAny(filter.ExcludeRegexps, texts) {
+		return false, nil
+	}
+	return len(filter.IncludeRegexps) == 0 || matchesAny(filter.IncludeRegexps, texts), nil
+}
+
+// filterTexts returns the entry fields searched by keyword filters. Tags are
+// searched one by one, so patterns can anchor to a whole tag.
+func filterTexts(fields []string, entry *models.WebhookEntry) []string {
+	if len(fields) == 0 {
+		fields = config.FilterFields
+	}
+	var texts []string
+	for _, field := range fields {
+		switch field {
+		case config.FilterFieldTitle:
+			texts = append(texts, entry.Title)
+		case config.FilterFieldContent:
+			texts = append(texts, textutil.HTMLToText(entry.Content))
+		case config.FilterFieldAuthor:
+			texts = append(texts, entry.Author)
+		case config.FilterFieldTags:
+			texts = append(texts, entry.Tags...)
+		}
+	}
+	return texts
+}
+
+func matchesAny(res []*regexp.Regexp, texts []string) bool {
+	for _, re := range res {
+		for _, text := range texts {
+			if re.MatchString(text) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"testing"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/models"
+)
+
+func TestMatchesFilter_Keywords(t *testing.T) {
+	entry := &models.WebhookEntry{
+		Title:   "Patch Tuesday fixes CVE-2024-1234",
+		Content: "<p>An <b>0day</b> in Exchange is exploited in the wild.</p>",
+		Author:  "Security Team",
+		Tags:    []string{"Sponsored", "windows"},
+	}
+
+	tests := []struct {
+		name     string
+		filter   config.FilterConfig
+		expected bool
+	}{
+		{
+			name:     "empty filter",
+			filter:   config.FilterConfig{},
+			expected: true,
+		},
+		{
+			name:     "include keyword in title",
+			filter:   config.FilterConfig{Include: []string{"cve"}},
+			expected: true,
+		},
+		{
+			name:     "include keyword in content text",
+			filter:   config.FilterConfig{Include: []string{"0day"}},
+			expected: true,
+		},
+		{
+			name:     "content markup is not searched",
+			filter:   config.FilterConfig{Include: []string{"<b>"}},
+			expected: false,
+		},
+		{
+			name:     "include keyword missing",
+			filter:   config.FilterConfig{Include: []string{"linux"}},
+			expected: false,
+		},
+		{
+			name:     "case sensitive",
+			filter:   config.FilterConfig{Include: []string{"cve"}, CaseSensitive: true},
+			expected: false,
+		},
+		{
+			name:     "whole word",
+			filter:   config.FilterConfig{Include: []string{"Exchange", "Patch"}, WholeWord: true},
+			expected: true,
+		},
+		{
+			name:     "whole word rejects part of a word",
+			filter:   config.FilterConfig{Include: []string{"Tues", "xploit"}, WholeWord: true},
+			expected: false,
+		},
+		{
+			name:     "exclude wins over include",
+			filter:   config.FilterConfig{Include: []string{"CVE"}, Exclude: []string{"sponsored"}},
+			expected: false,
+		},
+		{
+			name:     "exclude limited to fields",
+			filter:   config.FilterConfig{Exclude: []string{"sponsored"}, Fields: []string{"title", "content"}},
+			expected: true,
+		},
+		{
+			name:     "author field",
+			filter:   config.FilterConfig{Include: []string{"security team"}, Fields: []string{"author"}},
+			expected: true,
+		},
+		{
+			name:     "include pattern",
+			filter:   config.FilterConfig{IncludePatterns: []string{`CVE-\d{4}-\d+`}},
+			expected: true,
+		},
+		{
+			name:     "exclude pattern anchored to a tag",
+			filter:   config.FilterConfig{ExcludePatterns: []string{`^sponsored$`}},
+			expected: false,
+		},
+		{
+			name:     "keyword or pattern",
+			filter:   config.FilterConfig{Include: []string{"linux"}, IncludePatterns: []string{`(?-i)Exchange`}},
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.filter.Compile(); err != nil {
+				t.Fatalf("Failed to compile filter: %v", err)
+			}
+			if result := MatchesFilter(tt.filter, entry, &models.WebhookFeed{}); result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestMatchesFilter_WholeWordCJK(t *testing.T) {
+	filter := config.FilterConfig{Include: []string{"漏洞"}, WholeWord: true}
+	if err := filter.Compile(); err != nil {
+		t.Fatalf("Failed to compile filter: %v", err)
+	}
+	if !MatchesFilter(filter, &models.WebhookEntry{Title: "严重漏洞预警"}, &models.WebhookFeed{}) {
+		t.Error("Expected a Chinese keyword to match inside a sentence")
+	}
+}
+
+func TestMatchesFilter_Expression(t *testing.T) {
+	feed := &models.WebhookFeed{ID: 1, Category: &models.WebhookCategory{Title: "Research"}}
+	filter := config.FilterConfig{
+		Expression: `reading_time > 5 && feed.category.title == 'Research' && !(title matches '(?i)weekly')`,
+		Exclude:    []string{"sponsored"},
+	}
+	if err := filter.Compile(); err != nil {
+		t.Fatalf("Failed to compile filter: %v", err)
+	}
+
+	tests := []struct {
+		entry    *models.WebhookEntry
+		expected bool
+	}{
+		{&models.WebhookEntry{Title: "Scaling laws", ReadingTime: 12}, true},
+		{&models.WebhookEntry{Title: "Weekly digest", ReadingTime: 12}, false},
+		{&models.WebhookEntry{Title: "Short note", ReadingTime: 2}, false},
+		{&models.WebhookEntry{Title: "Scaling laws", ReadingTime: 12, Tags: []string{"Sponsored"}}, false},
+	}
+
+	for _, tt := range tests {
+		if result := MatchesFilter(filter, tt.entry, feed); result != tt.expected {
+			t.Errorf("Expected %v for %q, got %v", tt.expected, tt.entry.Title, result)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/models"
+	"miniflux-feishu/internal/textutil"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Errors returned for lead images that cannot be used.
+var (
+	ErrImageTooLarge       = errors.New("image exceeds the size limit")
+	ErrImageTypeNotAllowed = errors.New("image type not allowed")
+)
+
+// imageTypes are the sniffed content types uploaded to Feishu.
+var imageTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp", "image/bmp"}
+
+// tokenExpiryMargin renews the tenant access token before Feishu expires it.
+const tokenExpiryMargin = 5 * time.Minute
+
+// imageFailureTTL is how long a lead image that failed is skipped, so that
+// retries of the same entry do not download and upload it again.
+const imageFailureTTL = 10 * time.Minute
+
+// Open platform error codes for a tenant access token that is no longer valid.
+const (
+	feishuCodeInvalidToken = 99991663 // invalid access token
+	feishuCodeExpiredToken = 99991668 // access token expired
+)
+
+// apiError is a non-zero code or unexpected status from the open platform.
+type apiError struct {
+	StatusCode int
+	Code       int
+	Msg        string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("feishu API returned status %d, code %d: %s", e.StatusCode, e.Code, e.Msg)
+}
+
+// imageFailure remembers why a lead image could not be used.
+type imageFailure struct {
+	err   error
+	until time.Time
+}
+
+// imageUploader downloads lead images and uploads them through the Feishu
+// image API, remembering the image key of each URL so that an image shared by
+// several entries is only uploaded once.
+type imageUploader struct {
+	app     config.AppConfig
+	maxSize int64
+	// api talks to the open platform, download fetches images from any public
+	// host
+	api      *http.Client
+	download *http.Client
+	now      func() time.Time
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+	keys        map[string]string
+	failures    map[string]imageFailure
+	// order lists the cached URL hashes oldest first, for eviction
+	order     []string
+	cacheSize int
+}
+
+func newImageUploader(cfg *config.Config, api, download *http.Client, now func() time.Time) *imageUploader {
+	return &imageUploader{
+		app:       cfg.App,
+		maxSize:   cfg.Images.MaxSize,
+		api:       api,
+		download:  download,
+		now:       now,
+		keys:      make(map[string]string),
+		failures:  make(map[string]imageFailure),
+		cacheSize: cfg.Images.CacheSize,
+	}
+}
+
+// attachLeadImage puts the entry's lead image at the top of a card. The card
+// is sent without it when the image cannot be fetched or uploaded.
+func (s *FeishuService) attachLeadImage(ctx context.Context, message *FeishuMessage, entry *models.WebhookEntry, dest *config.Destination) {
+	if s.images == nil || message.Card == nil || !dest.Card.LeadImage {
+		return
+	}
+	imageKey, err := s.images.LeadImageKey(ctx, entry)
+	if err != nil {
+		imageMetrics.Add("failed", 1)
+		log.Printf("Sending entry %d without its lead image: %v", entry.ID, err)
+		return
+	}
+	if imageKey == "" {
+		return
+	}
+	image := FeishuCardElement{
+		Tag:    "img",
+		ImgKey: imageKey,
+		Alt:    &FeishuCardText{Tag: "plain_text", Content: entry.Title},
+	}
+	message.Card.Elements = append([]FeishuCardElement{image}, message.Card.Elements...)
+}
+
+// LeadImageKey returns the image key of the entry's lead image, or "" when the
+// entry has no image.
+func (u *imageUploader) LeadImageKey(ctx context.Context, entry *models.WebhookEntry) (string, error) {
+	imageURL := leadImageURL(entry)
+	if imageURL == "" {
+		return "", nil
+	}
+	sum := sha256.Sum256([]byte(imageURL))
+	hash := hex.EncodeToString(sum[:])
+
+	u.mu.Lock()
+	imageKey, ok := u.keys[hash]
+	failure, failed := u.failures[hash]
+	u.mu.Unlock()
+	if ok {
+		imageMetrics.Add("cached", 1)
+		return imageKey, nil
+	}
+	if failed && u.now().Before(failure.until) {
+		return "", fmt.Errorf("skipping %s, it failed recently: %w", imageURL, failure.err)
+	}
+
+	data, err := u.fetch(ctx, imageURL)
+	if err != nil {
+		return "", u.fail(ctx, hash, fmt.Errorf("failed to download %s: %w", imageURL, err))
+	}
+	imageKey, err = u.upload(ctx, data)
+	if err != nil {
+		return "", u.fail(ctx, hash, fmt.Errorf("failed to upload %s: %w", imageURL, err))
+	}
+	imageMetrics.Add("uploaded", 1)
+
+	u.mu.Lock()
+	delete(u.failures, hash)
+	if _, ok := u.keys[hash]; !ok {
+		u.keys[hash] = imageKey
+		u.order = append(u.order, hash)
+		if len(u.order) > u.cacheSize {
+			delete(u.keys, u.order[0])
+			u.order = u.order[1:]
+		}
+	}
+	u.mu.Unlock()
+	return imageKey, nil
+}
+
+// fail remembers that an image failed and returns err. Failures caused by the
+// caller giving up are not remembered.
+func (u *imageUploader) fail(ctx context.Context, hash string, err error) error {
+	if ctx.Err() != nil {
+		return err
+	}
+	now := u.now()
+	u.mu.Lock()
+	for h, failure := range u.failures {
+		if !now.Before(failure.until) {
+			delete(u.failures, h)
+		}
+	}
+	u.failures[hash] = imageFailure{err: err, until: now.Add(imageFailureTTL)}
+	u.mu.Unlock()
+	return err
+}
+
+// fetch downloads an image, enforcing the size limit and checking its type
+// from the content rather than from the response headers.
+func (u *imageUploader) fetch(ctx context.Context, imageURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", imageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "miniflux-feishu/1.0.0")
+
+	resp, err := u.download.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if resp.ContentLength > u.maxSize {
+		return nil, ErrImageTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, u.maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > u.maxSize {
+		return nil, ErrImageTooLarge
+	}
+	if contentType := http.DetectContentType(data); !slices.Contains(imageTypes, contentType) {
+		return nil, fmt.Errorf("%w: %s", ErrImageTypeNotAllowed, contentType)
+	}
+	return data, nil
+}
+
+// upload sends the image to the Feishu image API and returns its key.
+func (u *imageUploader) upload(ctx context.Context, data []byte) (string, error) {
+	token, err := u.tenantToken(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if err := form.WriteField("image_type", "message"); err != nil {
+		return "", err
+	}
+	part, err := form.CreateFormFile("image", "image")
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", err
+	}
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Data struct {
+			ImageKey string `json:"image_key"`
+		} `json:"data"`
+	}
+	err = u.call(ctx, "/open-apis/im/v1/images", form.FormDataContentType(), token, &body, &result)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && (apiErr.Code == feishuCodeInvalidToken || apiErr.Code == feishuCodeExpiredToken) {
+		// The token was revoked or expired early, fetch a new one next time
+		u.mu.Lock()
+		if u.token == token {
+			u.token = ""
+		}
+		u.mu.Unlock()
+	}
+	if err != nil {
+		return "", err
+	}
+	if result.Data.ImageKey == "" {
+		return "", errors.New("feishu API returned no image_key")
+	}
+	return result.Data.ImageKey, nil
+}
+
+// tenantToken returns a tenant access token for the app, requesting a new one
+// when the cached token is about to expire.
+func (u *imageUploader) tenantToken(ctx context.Context) (string, error) {
+	u.mu.Lock()
+	if u.token != "" && u.now().Before(u.tokenExpiry) {
+		token := u.token
+		u.mu.Unlock()
+		return token, nil
+	}
+	u.mu.Unlock()
+
+	payload, err := json.Marshal(map[string]string{"app_id": u.app.AppID, "app_secret": u.app.AppSecret})
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		TenantAccessToken string `json:"tenant_access_token"`
+		Expire            int    `json:"expire"`
+	}
+	err = u.call(ctx, "/open-apis/auth/v3/tenant_access_token/internal", "application/json", "", bytes.NewReader(payload), &result)
+	if err != nil {
+		return "", fmt.Errorf("failed to get tenant access token: %w", err)
+	}
+
+	u.mu.Lock()
+	u.token = result.TenantAccessToken
+	u.tokenExpiry = u.now().Add(time.Duration(result.Expire)*time.Second - tokenExpiryMargin)
+	u.mu.Unlock()
+	return result.TenantAccessToken, nil
+}
+
+// call posts to an open platform API and decodes its response into result,
+// turning a non-zero code into an error.
+func (u *imageUploader) call(ctx context.Context, path, contentType, token string, body io.Reader, result any) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(u.app.BaseURL, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "miniflux-feishu/1.0.0")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := u.api.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodySize))
+	if err != nil {
+		return err
+	}
+	var status feishuResponse
+	if err := json.Unmarshal(respBody, &status); err != nil {
+		return fmt.Errorf("feishu API returned status %d: %s", resp.StatusCode, respBody)
+	}
+	if resp.StatusCode != http.StatusOK || status.Code != 0 {
+		return &apiError{StatusCode: resp.StatusCode, Code: status.Code, Msg: status.Msg}
+	}
+	return json.Unmarshal(respBody, result)
+}
+
+// validateImageURL accepts images from any public http or https host.
+func (s *FeishuService) validateImageURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Errorf("%w: unsupported scheme %q", ErrWebhookURLNotAllowed, u.Scheme)
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !s.security.AllowPrivateNetworks && isBlockedIP(ip) {
+		return fmt.Errorf("%w: address %s is not routable", ErrWebhookURLNotAllowed, ip)
+	}
+	return nil
+}
+
+// leadImageURL picks the first image enclosure, or else the first image in
+// the entry content.
+func leadImageURL(entry *models.WebhookEntry) string {
+	for _, e := range entry.Enclosures {
+		if mediaKind(e.MimeType) == "image" {
+			if link := textutil.LinkURL(e.URL); link != "" {
+				return link
+			}
+		}
+	}
+
+	base, _ := url.Parse(entry.URL)
+	tokenizer := html.NewTokenizer(strings.NewReader(entry.Content))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			if token.DataAtom != atom.Img {
+				continue
+			}
+			// Skip tracking pixels
+			if textutil.Attr(token.Attr, "width") == "1" || textutil.Attr(token.Attr, "height") == "1" {
+				continue
+			}
+			src, err := url.Parse(strings.TrimSpace(textutil.Attr(token.Attr, "src")))
+			if err != nil || src.String() == "" {
+				continue
+			}
+			if base != nil {
+				src = base.ResolveReference(src)
+			}
+			if link := textutil.LinkURL(src.String()); link != "" {
+				return link
+			}
+		}
+	}
+}
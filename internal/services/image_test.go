@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/models"
+)
+
+var testPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01")
+
+// feishuImageServer stands in for both the Feishu open platform and the sites
+// serving images. It counts token requests, uploads and downloads of the
+// broken image, and fails uploads with uploadCode when it is set.
+type feishuImageServer struct {
+	*httptest.Server
+	tokens     atomic.Int32
+	uploads    atomic.Int32
+	broken     atomic.Int32
+	uploadCode atomic.Int32
+}
+
+func newFeishuImageServer(t *testing.T) *feishuImageServer {
+	t.Helper()
+	s := &feishuImageServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /open-apis/auth/v3/tenant_access_token/internal", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["app_id"] != "cli_test" || body["app_secret"] != "app-secret" {
+			w.Write([]byte(`{"code":10014,"msg":"app secret invalid"}`)) //nolint:errcheck
+			return
+		}
+		s.tokens.Add(1)
+		w.Write([]byte(`{"code":0,"msg":"ok","tenant_access_token":"t-test","expire":7200}`)) //nolint:errcheck
+	})
+	mux.HandleFunc("POST /open-apis/im/v1/images", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t-test" {
+			w.Write([]byte(`{"code":99991663,"msg":"invalid access token"}`)) //nolint:errcheck
+			return
+		}
+		if code := s.uploadCode.Load(); code != 0 {
+			w.Write([]byte(`{"code":` + strconv.Itoa(int(code)) + `,"msg":"upload failed"}`)) //nolint:errcheck
+			return
+		}
+		if r.FormValue("image_type") != "message" {
+			t.Errorf("Expected image_type message, got %q", r.FormValue("image_type"))
+		}
+		file, _, err := r.FormFile("image")
+		if err != nil {
+			t.Errorf("Expected an image file: %v", err)
+			return
+		}
+		file.Close() //nolint:errcheck
+		n := s.uploads.Add(1)
+		w.Write([]byte(`{"code":0,"msg":"success","data":{"image_key":"img_v2_` + strconv.Itoa(int(n)) + `"}}`)) //nolint:errcheck
+	})
+	mux.HandleFunc("GET /images/photo.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(testPNG) //nolint:errcheck
+	})
+	mux.HandleFunc("GET /images/broken.png", func(w http.ResponseWriter, r *http.Request) {
+		s.broken.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	mux.HandleFunc("GET /images/large.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(append(testPNG, make([]byte, 2048)...)) //nolint:errcheck
+	})
+	mux.HandleFunc("GET /images/page.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("<html><body>not an image</body></html>")) //nolint:errcheck
+	})
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func imageTestConfig(baseURL string) *config.Config {
+	cfg := testConfig()
+	cfg.App = config.AppConfig{AppID: "cli_test", AppSecret: "app-secret", BaseURL: baseURL}
+	cfg.Images = config.ImagesConfig{MaxSize: 1024, CacheSize: 10}
+	return cfg
+}
+
+func TestImageUploader_LeadImageKey(t *testing.T) {
+	server := newFeishuImageServer(t)
+	service := NewFeishuService(imageTestConfig(server.URL))
+
+	entry := &models.WebhookEntry{
+		URL:     server.URL + "/posts/1",
+		Content: `<p>Intro</p><img src="/images/photo.png" alt="Photo">`,
+	}
+	for i := 0; i < 2; i++ {
+		imageKey, err := service.images.LeadImageKey(context.Background(), entry)
+		if err != nil {
+			t.Fatalf("Failed to get lead image key: %v", err)
+		}
+		if imageKey != "img_v2_1" {
+			t.Errorf("Expected image key img_v2_1, got %q", imageKey)
+		}
+	}
+	if uploads := server.uploads.Load(); uploads != 1 {
+		t.Errorf("Expected the image to be uploaded once, got %d uploads", uploads)
+	}
+	if tokens := server.tokens.Load(); tokens != 1 {
+		t.Errorf("Expected one token request, got %d", tokens)
+	}
+
+	// Entries without images need no upload
+	imageKey, err := service.images.LeadImageKey(context.Background(), &models.WebhookEntry{Content: "<p>Text only</p>"})
+	if err != nil || imageKey != "" {
+		t.Errorf("Expected no image key, got %q, %v", imageKey, err)
+	}
+}
+
+func TestImageUploader_Limits(t *testing.T) {
+	server := newFeishuImageServer(t)
+	service := NewFeishuService(imageTestConfig(server.URL))
+
+	tests := []struct {
+		name     string
+		path     string
+		expected error
+	}{
+		{name: "too large", path: "/images/large.png", expected: ErrImageTooLarge},
+		{name: "not an image", path: "/images/page.html", expected: ErrImageTypeNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := &models.WebhookEntry{Content: `<img src="` + server.URL + tt.path + `">`}
+			if _, err := service.images.LeadImageKey(context.Background(), entry); !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+	if uploads := server.uploads.Load(); uploads != 0 {
+		t.Errorf("Expected no uploads, got %d", uploads)
+	}
+}
+
+func TestImageUploader_TokenReset(t *testing.T) {
+	server := newFeishuImageServer(t)
+	service := NewFeishuService(imageTestConfig(server.URL))
+
+	upload := func(path string) error {
+		entry := &models.WebhookEntry{Content: `<img src="` + server.URL + path + `">`}
+		_, err := service.images.LeadImageKey(context.Background(), entry)
+		return err
+	}
+
+	tests := []struct {
+		name     string
+		code     int32
+		expected int32
+	}{
+		{name: "other error keeps the token", code: 234001, expected: 1},
+		{name: "invalid token", code: 99991663, expected: 2},
+		{name: "expired token", code: 99991668, expected: 3},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.uploadCode.Store(tt.code)
+			if err := upload("/images/photo.png?failed=" + strconv.Itoa(i)); err == nil {
+				t.Fatal("Expected the upload to fail")
+			}
+			server.uploadCode.Store(0)
+			if err := upload("/images/photo.png?ok=" + strconv.Itoa(i)); err != nil {
+				t.Fatalf("Failed to upload: %v", err)
+			}
+			if tokens := server.tokens.Load(); tokens != tt.expected {
+				t.Errorf("Expected %d token requests, got %d", tt.expected, tokens)
+			}
+		})
+	}
+}
+
+func TestImageUploader_RemembersFailures(t *testing.T) {
+	server := newFeishuImageServer(t)
+	service := NewFeishuService(imageTestConfig(server.URL))
+	now := time.Now()
+	service.now = func() time.Time { return now }
+
+	entry := &models.WebhookEntry{Content: `<img src="` + server.URL + `/images/broken.png">`}
+	for i := 0; i < 3; i++ {
+		if _, err := service.images.LeadImageKey(context.Background(), entry); err == nil {
+			t.Fatal("Expected the broken image to fail")
+		}
+	}
+	if downloads := server.broken.Load(); downloads != 1 {
+		t.Errorf("Expected one download of the broken image, got %d", downloads)
+	}
+
+	// The image is tried again once the failure is forgotten
+	now = now.Add(imageFailureTTL)
+	if _, err := service.images.LeadImageKey(context.Background(), entry); err == nil {
+		t.Fatal("Expected the broken image to fail")
+	}
+	if downloads := server.broken.Load(); downloads != 2 {
+		t.Errorf("Expected two downloads of the broken image, got %d", downloads)
+	}
+}
+
+func TestLeadImageURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		entry    *models.WebhookEntry
+		expected string
+	}{
+		{
+			name: "image enclosure first",
+			entry: &models.WebhookEntry{
+				Content:    `<img src="https://example.org/inline.jpg">`,
+				Enclosures: []models.WebhookEnclosure{{URL: "https://example.org/episode.mp3", MimeType: "audio/mpeg"}, {URL: "https://example.org/cover.jpg", MimeType: "image/jpeg"}},
+			},
+			expected: "https://example.org/cover.jpg",
+		},
+		{
+			name:     "relative image",
+			entry:    &models.WebhookEntry{URL: "https://example.org/blog/post", Content: `<p><img src="../img/a.png"></p>`},
+			expected: "https://example.org/img/a.png",
+		},
+		{
+			name:     "tracking pixel and data URI skipped",
+			entry:    &models.WebhookEntry{Content: `<img src="https://tracker.example.com/p.gif" width="1" height="1"><img src="data:image/png;base64,AAAA"><img src="https://example.org/b.png">`},
+			expected: "https://example.org/b.png",
+		},
+		{
+			name:     "no image",
+			entry:    &models.WebhookEntry{Content: `<p>Nothing to see</p>`},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := leadImageURL(tt.entry); result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestFeishuService_SendEntryToFeishu_LeadImage(t *testing.T) {
+	server := newFeishuImageServer(t)
+	service := NewFeishuService(imageTestConfig(server.URL))
+
+	var captured FeishuMessage
+	bot := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&captured); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		w.Write([]byte(`{"code":0,"msg":"success"}`)) //nolint:errcheck
+	}))
+	defer bot.Close()
+
+	entry := &models.WebhookEntry{ID: 231, Title: "Example", URL: "https://example.org/article", Content: `<img src="` + server.URL + `/images/photo.png"><p>Body</p>`}
+	feed := &models.WebhookFeed{ID: 8, Title: "Example website"}
+	dest := &config.Destination{Name: "team", WebhookURL: bot.URL, Format: config.FormatCard, Card: config.CardConfig{LeadImage: true}}
+
+	if err := service.SendEntryToFeishu(context.Background(), entry, feed, dest); err != nil {
+		t.Fatalf("Failed to send entry: %v", err)
+	}
+	if captured.Card == nil || len(captured.Card.Elements) == 0 {
+		t.Fatalf("Expected a card, got %+v", captured)
+	}
+	if image := captured.Card.Elements[0]; image.Tag != "img" || image.ImgKey != "img_v2_1" {
+		t.Errorf("Expected the lead image first, got %+v", image)
+	}
+
+	// A broken image does not hold back the entry
+	entry.Content = `<img src="` + server.URL + `/images/missing.png"><p>Body</p>`
+	if err := service.SendEntryToFeishu(context.Background(), entry, feed, dest); err != nil {
+		t.Fatalf("Failed to send entry: %v", err)
+	}
+	if captured.Card.Elements[0].Tag == "img" {
+		t.Errorf("Expected the card without an image, got %+v", captured.Card.Elements[0])
+	}
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"html"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/i18n"
+	"miniflux-feishu/internal/models"
+)
+
+// mentionAll is the user id Feishu uses for @all.
+const mentionAll = "all"
+
+// mentions are the users mentioned in one message.
+type mentions struct {
+	All     bool
+	UserIDs []string
+}
+
+// matchMentions collects the users of every rule the entry matches.
+func matchMentions(rules []config.MentionRule, entry *models.WebhookEntry, feed *models.WebhookFeed) mentions {
+	var m mentions
+	for _, rule := range rules {
+		if !MatchesFilter(rule.Filter, entry, feed) {
+			continue
+		}
+		m.All = m.All || rule.All
+		for _, id := range rule.UserIDs {
+			id = strings.TrimSpace(id)
+			if !slices.Contains(m.UserIDs, id) {
+				m.UserIDs = append(m.UserIDs, id)
+			}
+		}
+	}
+	return m
+}
+
+func (m mentions) empty() bool {
+	return !m.All && len(m.UserIDs) == 0
+}
+
+// ids lists the mentioned user ids, "all" first.
+func (m mentions) ids() []string {
+	if m.All {
+		return append([]string{mentionAll}, m.UserIDs...)
+	}
+	return m.UserIDs
+}
+
+// text uses the <at> syntax of text messages. Feishu shows the user's name,
+// so only @all gets a label.
+func (m mentions) text(locale *i18n.Locale) string {
+	parts := make([]string, 0, len(m.ids()))
+	for _, id := range m.ids() {
+		label := ""
+		if id == mentionAll {
+			label = locale.T(i18n.Everyone)
+		}
+		parts = append(parts, `<at user_id="`+html.EscapeString(id)+`">`+html.EscapeString(label)+`</at>`)
+	}
+	return strings.Join(parts, " ")
+}
+
+// markdown uses the <at> syntax of card lark_md text.
+func (m mentions) markdown() string {
+	parts := make([]string, 0, len(m.ids()))
+	for _, id := range m.ids() {
+		parts = append(parts, "<at id="+id+"></at>")
+	}
+	return strings.Join(parts, " ")
+}
+
+func (m mentions) postParagraph() []FeishuPostElement {
+	paragraph := make([]FeishuPostElement, 0, len(m.ids()))
+	for _, id := range m.ids() {
+		paragraph = append(paragraph, FeishuPostElement{Tag: "at", UserID: id})
+	}
+	return paragraph
+}
+
+// mentionLimiter counts the messages with mentions each destination sent on
+// the current day. Counts are kept in memory and start over after a restart.
+type mentionLimiter struct {
+	mu     sync.Mutex
+	counts map[string]mentionCount
+}
+
+type mentionCount struct {
+	day   string
+	count int
+}
+
+func newMentionLimiter() *mentionLimiter {
+	return &mentionLimiter{counts: make(map[string]mentionCount)}
+}
+
+// mentionReservation is a mention counted against the limit of a day before
+// the message was sent.
+type mentionReservation struct {
+	dest string
+	day  string
+}
+
+// take counts a message with mentions for the destination, or returns nil
+// when the limit of the day is reached.
+func (l *mentionLimiter) take(dest, day string, limit int) *mentionReservation {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c := l.counts[dest]
+	if c.day != day {
+		c = mentionCount{day: day}
+	}
+	if c.count >= limit {
+		return nil
+	}
+	c.count++
+	l.counts[dest] = c
+	return &mentionReservation{dest: dest, day: day}
+}
+
+// refund gives back a reservation whose message was not sent, so that failed
+// deliveries and their replays do not use up the mentions of the day.
+func (l *mentionLimiter) refund(r *mentionReservation) {
+	if r == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if c := l.counts[r.dest]; c.day == r.day && c.count > 0 {
+		c.count--
+		l.counts[r.dest] = c
+	}
+}
+
+// addMentions mentions the users of the destination's matching rules in a
+// text, post or card message, unless the destination used up its mentions
+// for the day. Template output is left alone. The returned reservation has to
+// be refunded if the message is not sent.
+func (s *FeishuService) addMentions(message *FeishuMessage, entry *models.WebhookEntry, feed *models.WebhookFeed, dest *config.Destination) *mentionReservation {
+	if len(dest.Mentions.Rules) == 0 || message.Rendered != nil {
+		return nil
+	}
+	m := matchMentions(dest.Mentions.Rules, entry, feed)
+	if m.empty() {
+		return nil
+	}
+
+	limit := dest.Mentions.DailyLimit
+	if limit == 0 {
+		limit = config.DefaultMentionDailyLimit
+	}
+	now := s.now()
+	if dest.Location != nil {
+		now = now.In(dest.Location)
+	}
+	reservation := s.mentions.take(dest.Name, now.Format("2006-01-02"), limit)
+	if reservation == nil {
+		mentionMetrics.Add("capped", 1)
+		log.Printf("Daily mention limit of %s reached, sending entry %d without mentions", dest.Name, entry.ID)
+		return nil
+	}
+
+	switch {
+	case message.Card != nil:
+		element := FeishuCardElement{Tag: "markdown", Content: m.markdown()}
+		// Keep the divider and the buttons as the footer
+		elements := message.Card.Elements
+		i := len(elements)
+		if i > 0 && elements[i-1].Tag == "action" {
+			i--
+		}
+		if i > 0 && elements[i-1].Tag == "hr" {
+			i--
+		}
+		message.Card.Elements = slices.Insert(elements, i, element)
+	case message.Content.Post != nil:
+		for lang, body := range message.Content.Post {
+			body.Content = append(body.Content, m.postParagraph())
+			message.Content.Post[lang] = body
+		}
+	default:
+		text := m.text(s.localeFor(dest))
+		if message.Content.Content != "" {
+			text = message.Content.Content + "\n\n" + text
+		}
+		message.Content.Content = text
+	}
+	return reservation
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/models"
+)
+
+func mentionRule(t *testing.T, rule config.MentionRule) config.MentionRule {
+	t.Helper()
+	if err := rule.Filter.Compile(); err != nil {
+		t.Fatalf("Failed to compile filter: %v", err)
+	}
+	return rule
+}
+
+func TestMatchMentions(t *testing.T) {
+	rules := []config.MentionRule{
+		mentionRule(t, config.MentionRule{Filter: config.FilterConfig{Include: []string{"outage"}}, All: true}),
+		mentionRule(t, config.MentionRule{Filter: config.FilterConfig{Include: []string{"outage", "degraded"}}, UserIDs: []string{"ou_oncall"}}),
+		mentionRule(t, config.MentionRule{Filter: config.FilterConfig{Expression: `feed.id == 8`}, UserIDs: []string{"ou_oncall", "ou_lead"}}),
+	}
+	feed := &models.WebhookFeed{ID: 3}
+
+	tests := []struct {
+		name     string
+		entry    *models.WebhookEntry
+		feed     *models.WebhookFeed
+		expected mentions
+	}{
+		{"no match", &models.WebhookEntry{Title: "Scheduled maintenance"}, feed, mentions{}},
+		{"single rule", &models.WebhookEntry{Title: "Degraded performance"}, feed, mentions{UserIDs: []string{"ou_oncall"}}},
+		{"several rules", &models.WebhookEntry{Title: "Major outage"}, feed, mentions{All: true, UserIDs: []string{"ou_oncall"}}},
+		{"users once", &models.WebhookEntry{Title: "Degraded performance"}, &models.WebhookFeed{ID: 8}, mentions{UserIDs: []string{"ou_oncall", "ou_lead"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := matchMentions(rules, tt.entry, tt.feed); !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestFeishuService_AddMentions(t *testing.T) {
+	service := NewFeishuService(testConfig())
+	entry := &models.WebhookEntry{ID: 1, Title: "Major outage", URL: "https://status.example.com/1", Content: "<p>API is down</p>"}
+	feed := &models.WebhookFeed{ID: 8, Title: "Status"}
+	rules := []config.MentionRule{
+		mentionRule(t, config.MentionRule{Filter: config.FilterConfig{Include: []string{"outage"}}, All: true, UserIDs: []string{"ou_oncall"}}),
+	}
+
+	t.Run("text", func(t *testing.T) {
+		dest := &config.Destination{Name: "text", Locale: "zh-CN", Mentions: config.MentionConfig{Rules: rules}}
+		message, _ := service.RenderMessage(entry, feed, dest)
+		service.addMentions(&message, entry, feed, dest)
+		expected := "API is down\n\n" + `<at user_id="all">所有人</at> <at user_id="ou_oncall"></at>`
+		if message.Content.Content != expected {
+			t.Errorf("Expected %q, got %q", expected, message.Content.Content)
+		}
+	})
+
+	t.Run("post", func(t *testing.T) {
+		dest := &config.Destination{Name: "post", Format: config.FormatPost, Mentions: config.MentionConfig{Rules: rules}}
+		message, _ := service.RenderMessage(entry, feed, dest)
+		service.addMentions(&message, entry, feed, dest)
+		content := message.Content.Post["en_us"].Content
+		expected := []FeishuPostElement{{Tag: "at", UserID: "all"}, {Tag: "at", UserID: "ou_oncall"}}
+		if last := content[len(content)-1]; !reflect.DeepEqual(last, expected) {
+			t.Errorf("Expected last paragraph %+v, got %+v", expected, last)
+		}
+	})
+
+	t.Run("card", func(t *testing.T) {
+		dest := &config.Destination{Name: "card", Format: config.FormatCard, Mentions: config.MentionConfig{Rules: rules}}
+		message, _ := service.RenderMessage(entry, feed, dest)
+		service.addMentions(&message, entry, feed, dest)
+		elements := message.Card.Elements
+		if n := len(elements); elements[n-2].Tag != "hr" || elements[n-1].Tag != "action" {
+			t.Errorf("Expected the divider and buttons to stay last, got %s and %s", elements[n-2].Tag, elements[n-1].Tag)
+		}
+		mention := elements[len(elements)-3]
+		if mention.Tag != "markdown" || mention.Content != "<at id=all></at> <at id=ou_oncall></at>" {
+			t.Errorf("Expected a markdown element with the mentions, got %+v", mention)
+		}
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		other := &models.WebhookEntry{ID: 2, Title: "All systems operational"}
+		dest := &config.Destination{Name: "nomatch", Mentions: config.MentionConfig{Rules: rules}}
+		message, _ := service.RenderMessage(other, feed, dest)
+		service.addMentions(&message, other, feed, dest)
+		if message.Content.Content != "" {
+			t.Errorf("Expected no mentions, got %q", message.Content.Content)
+		}
+	})
+}
+
+func TestFeishuService_AddMentions_DailyLimit(t *testing.T) {
+	service := NewFeishuService(testConfig())
+	now := time.Date(2024, 3, 1, 15, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatalf("Failed to load time zone: %v", err)
+	}
+	dest := &config.Destination{
+		Name:     "status",
+		Location: shanghai,
+		Mentions: config.MentionConfig{
+			Rules:      []config.MentionRule{mentionRule(t, config.MentionRule{All: true})},
+			DailyLimit: 2,
+		},
+	}
+	entry := &models.WebhookEntry{ID: 1, Title: "Outage"}
+	feed := &models.WebhookFeed{ID: 8, Title: "Status"}
+
+	mentioned := func() bool {
+		message, _ := service.RenderMessage(entry, feed, dest)
+		service.addMentions(&message, entry, feed, dest)
+		return message.Content.Content != ""
+	}
+
+	for i, expected := range []bool{true, true, false} {
+		if result := mentioned(); result != expected {
+			t.Errorf("Message %d: expected mentioned %v, got %v", i+1, expected, result)
+		}
+	}
+
+	// 15:00 UTC is 23:00 in Shanghai, two hours later it is the next day there
+	now = now.Add(2 * time.Hour)
+	if !mentioned() {
+		t.Error("Expected the limit to reset on the next day of the destination's time zone")
+	}
+}
+
+func TestFeishuService_SendEntryToFeishu_MentionsCountOnlyWhenSent(t *testing.T) {
+	// The first delivery fails for good and is replayed later
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":9499,"msg":"Bad Request"}`)) //nolint:errcheck
+			return
+		}
+		w.Write([]byte(`{"code":0}`)) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	service := NewFeishuService(testConfig())
+	dest := &config.Destination{
+		Name:       "status",
+		WebhookURL: server.URL,
+		Retry:      config.RetryPolicy{MaxAttempts: 1},
+		Mentions: config.MentionConfig{
+			Rules:      []config.MentionRule{mentionRule(t, config.MentionRule{All: true})},
+			DailyLimit: 1,
+		},
+	}
+	entry := &models.WebhookEntry{ID: 1, Title: "Outage"}
+	feed := &models.WebhookFeed{ID: 8, Title: "Status"}
+
+	if err := service.SendEntryToFeishu(context.Background(), entry, feed, dest); err == nil {
+		t.Fatal("Expected the first delivery to fail")
+	}
+	if err := service.SendEntryToFeishu(context.Background(), entry, feed, dest); err != nil {
+		t.Fatalf("Expected the replay to succeed, got %v", err)
+	}
+	if err := service.SendEntryToFeishu(context.Background(), entry, feed, dest); err != nil {
+		t.Fatalf("Expected the next entry to be sent, got %v", err)
+	}
+
+	if len(bodies) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(bodies))
+	}
+	// The failed delivery gives its mention back to the replay, which uses up
+	// the limit of the day
+	for i, expected := range []bool{true, true, false} {
+		if mentioned := strings.Contains(bodies[i], `user_id=\"all\"`); mentioned != expected {
+			t.Errorf("Request %d: expected mentioned %v, got %v", i+1, expected, mentioned)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/i18n"
+	"miniflux-feishu/internal/models"
+	"miniflux-feishu/internal/textutil"
+)
+
+// entryMetadata holds the entry details shown next to its summary, formatted
+// for the destination.
+type entryMetadata struct {
+	Author      string
+	Published   string
+	ReadingTime string
+	// Hashtags are the entry tags, such as "#golang"
+	Hashtags    []string
+	CommentsURL string
+}
+
+func (s *FeishuService) entryMetadata(entry *models.WebhookEntry, dest *config.Destination) entryMetadata {
+	locale := s.localeFor(dest)
+	m := entryMetadata{
+		Author:      strings.TrimSpace(entry.Author),
+		Hashtags:    hashtags(entry.Tags),
+		CommentsURL: textutil.LinkURL(entry.CommentsURL),
+	}
+	if !entry.Date.IsZero() {
+		m.Published = s.formatPublished(entry.Date, dest)
+	}
+	if entry.ReadingTime > 0 {
+		m.ReadingTime = locale.N(i18n.Minutes, entry.ReadingTime)
+	}
+	return m
+}
+
+// formatPublished formats a publish time in the destination's locale and time
+// zone.
+func (s *FeishuService) formatPublished(t time.Time, dest *config.Destination) string {
+	if dest.Location != nil {
+		t = t.In(dest.Location)
+	}
+	return s.localeFor(dest).FormatPublished(t, s.now())
+}
+
+// byline joins the author, publish time and reading time.
+func (m entryMetadata) byline() string {
+	var parts []string
+	for _, part := range []string{m.Author, m.Published, m.ReadingTime} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " · ")
+}
+
+// header is the byline followed by the hashtags on a line of their own.
+func (m entryMetadata) header() string {
+	var lines []string
+	if byline := m.byline(); byline != "" {
+		lines = append(lines, byline)
+	}
+	if len(m.Hashtags) > 0 {
+		lines = append(lines, strings.Join(m.Hashtags, " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// hashtags turns tags into hashtags, replacing the spaces and punctuation that
+// would end a hashtag with underscores.
+func hashtags(tags []string) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		var b strings.Builder
+		underscore := false
+		for _, r := range strings.TrimSpace(tag) {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				b.WriteRune(r)
+				underscore = false
+			} else if !underscore && b.Len() > 0 {
+				b.WriteRune('_')
+				underscore = true
+			}
+		}
+		name := strings.TrimRight(b.String(), "_")
+		if name == "" || seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		result = append(result, "#"+name)
+	}
+	return result
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/models"
+)
+
+func TestHashtags(t *testing.T) {
+	tags := []string{"golang", "Some category", "C++ / Rust", "机器学习", "GoLang", "  ", "..."}
+	expected := []string{"#golang", "#Some_category", "#C_Rust", "#机器学习"}
+	if result := hashtags(tags); !slices.Equal(result, expected) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+}
+
+func metadataTestEntry() *models.WebhookEntry {
+	return &models.WebhookEntry{
+		ID:          1,
+		Title:       "Show HN: Example",
+		URL:         "https://example.org/article",
+		CommentsURL: "https://news.ycombinator.com/item?id=1",
+		Author:      "pg",
+		Date:        time.Date(2023, 8, 17, 19, 29, 0, 0, time.UTC),
+		ReadingTime: 5,
+		Tags:        []string{"startups", "open source"},
+		Content:     "<p>Summary</p>",
+	}
+}
+
+func TestFeishuService_FormatEntryMessage_Metadata(t *testing.T) {
+	service := NewFeishuService(testConfig())
+	service.now = func() time.Time { return time.Date(2023, 8, 17, 21, 29, 0, 0, time.UTC) }
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatalf("Failed to load time zone: %v", err)
+	}
+	feed := &models.WebhookFeed{Title: "Hacker News"}
+
+	tests := []struct {
+		name     string
+		dest     *config.Destination
+		expected string
+	}{
+		{
+			name:     "metadata disabled",
+			dest:     &config.Destination{},
+			expected: "Summary",
+		},
+		{
+			name:     "metadata in the destination time zone",
+			dest:     &config.Destination{Metadata: true, Location: shanghai},
+			expected: "pg · Aug 18, 2023 03:29 (2 hours ago) · 5 min\n#startups #open_source\n\nSummary\n\nComments: https://news.ycombinator.com/item?id=1",
+		},
+		{
+			name:     "localized metadata",
+			dest:     &config.Destination{Metadata: true, Locale: "zh-CN"},
+			expected: "pg · 2023年8月17日 19:29 (2 小时前) · 5 分钟\n#startups #open_source\n\nSummary\n\n评论: https://news.ycombinator.com/item?id=1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := service.formatEntryMessage(metadataTestEntry(), feed, tt.dest)
+			if message.Content.Content != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, message.Content.Content)
+			}
+		})
+	}
+}
+
+func TestFeishuService_FormatEntryPost_Metadata(t *testing.T) {
+	service := NewFeishuService(testConfig())
+	feed := &models.WebhookFeed{Title: "Hacker News"}
+
+	message := service.formatEntryPost(metadataTestEntry(), feed, &config.Destination{Format: config.FormatPost, Metadata: true})
+	content := message.Content.Post["en_us"].Content
+	if len(content) != 4 {
+		t.Fatalf("Expected byline, hashtags, summary and links paragraphs, got %+v", content)
+	}
+	if content[0][0].Text != "pg · Aug 17, 2023 19:29 · 5 min" {
+		t.Errorf("Unexpected byline %q", content[0][0].Text)
+	}
+	expectedLinks := []FeishuPostElement{
+		postLink("Open article", "https://example.org/article"),
+		postText(" · "),
+		postLink("Comments", "https://news.ycombinator.com/item?id=1"),
+	}
+	if !slices.EqualFunc(content[3], expectedLinks, func(a, b FeishuPostElement) bool {
+		return a.Tag == b.Tag && a.Text == b.Text && a.Href == b.Href
+	}) {
+		t.Errorf("Expected article and comments links, got %+v", content[3])
+	}
+}
+
+func TestFeishuService_FormatEntryCard_Metadata(t *testing.T) {
+	service := NewFeishuService(testConfig())
+	feed := &models.WebhookFeed{Title: "Hacker News"}
+
+	message := service.formatEntryCard(metadataTestEntry(), feed, &config.Destination{Format: config.FormatCard, Metadata: true})
+	elements := message.Card.Elements
+	fields := elements[0].Fields
+	if len(fields) != 4 || fields[3].Text.Content != "**Reading time**\n5 min" {
+		t.Errorf("Expected a reading time field, got %+v", fields)
+	}
+	if elements[2].Tag != "markdown" || elements[2].Content != "#startups #open&#95;source" {
+		t.Errorf("Expected the hashtags after the summary, got %+v", elements[2])
+	}
+	buttons := elements[len(elements)-1].Actions
+	if len(buttons) != 2 || buttons[1].URL != "https://news.ycombinator.com/item?id=1" {
+		t.Errorf("Expected a comments button, got %+v", buttons)
+	}
+}
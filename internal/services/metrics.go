@@ -0,0 +1,25 @@
+package services
+
+import "expvar"
+
+// Metrics are published through expvar, see /debug/vars.
+var (
+	// deliveryMetrics counts entries by final outcome: "sent" or "failed".
+	deliveryMetrics = expvar.NewMap("feishu_deliveries")
+	// sendErrorMetrics counts failed attempts by ErrorKind, including the
+	// ones that were retried successfully later.
+	sendErrorMetrics = expvar.NewMap("feishu_send_errors")
+	// destinationSentMetrics and destinationFailedMetrics count the final
+	// outcome of queued entries by destination name.
+	destinationSentMetrics   = expvar.NewMap("feishu_destination_sent")
+	destinationFailedMetrics = expvar.NewMap("feishu_destination_failed")
+	// rateLimitWaitMetrics sums the seconds spent waiting for the rate
+	// limiter, by destination name.
+	rateLimitWaitMetrics = expvar.NewMap("feishu_ratelimit_wait_seconds")
+	// imageMetrics counts lead images by outcome: "uploaded", "cached" or
+	// "failed".
+	imageMetrics = expvar.NewMap("feishu_lead_images")
+	// mentionMetrics counts messages with mentions by outcome: "sent", or
+	// "capped" when the daily limit dropped the mentions.
+	mentionMetrics = expvar.NewMap("feishu_mentions")
+)
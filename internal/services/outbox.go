@@ -0,0 +1,176 @@
+package services
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"miniflux-feishu/internal/config"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	pendingBucket = []byte("pending")
+	doneBucket    = []byte("done")
+)
+
+// Delivery outcomes stored with finished jobs.
+const (
+	JobStatusDelivered = "delivered"
+	JobStatusDead      = "dead"
+)
+
+// Outbox persists delivery jobs so that entries accepted from Miniflux survive
+// a restart. Jobs stay in the pending bucket until a worker has finished with
+// them, which gives at-least-once delivery. Delivered jobs are kept for the
+// retention period, jobs that failed go to the dead-letter store.
+type Outbox struct {
+	db        *bolt.DB
+	retention time.Duration
+	now       func() time.Time
+}
+
+func OpenOutbox(cfg *config.Config) (*Outbox, error) {
+	if dir := filepath.Dir(cfg.Outbox.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create outbox directory: %w", err)
+		}
+	}
+
+	db, err := bolt.Open(cfg.Outbox.Path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open outbox: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{pendingBucket, doneBucket, deadBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close() //nolint:errcheck
+		return nil, fmt.Errorf("failed to initialize outbox: %w", err)
+	}
+
+	return &Outbox{
+		db:        db,
+		retention: cfg.Outbox.Retention,
+		now:       time.Now,
+	}, nil
+}
+
+func (o *Outbox) Close() error {
+	return o.db.Close()
+}
+
+// Add stores the jobs in a single transaction and assigns their IDs.
+func (o *Outbox) Add(jobs []*DeliveryJob) error {
+	return o.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(pendingBucket)
+		for _, job := range jobs {
+			id, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+			job.ID = id
+			job.CreatedAt = o.now()
+			job.DestinationName = job.Destination.Name
+			if job.Destination.Legacy {
+				job.WebhookRef = webhookRef(job.Destination.WebhookURL)
+			}
+			if err := putJob(bucket, job); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// PendingAfter returns up to limit pending jobs with an ID greater than
+// afterID, in ID order. A limit of zero or less returns all of them.
+func (o *Outbox) PendingAfter(afterID uint64, limit int) ([]*DeliveryJob, error) {
+	var jobs []*DeliveryJob
+	err := o.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(pendingBucket).Cursor()
+		for k, v := cursor.Seek(itob(afterID + 1)); k != nil && (limit <= 0 || len(jobs) < limit); k, v = cursor.Next() {
+			job, err := decodeJob(k, v)
+			if err != nil {
+				return err
+			}
+			jobs = append(jobs, job)
+		}
+		return nil
+	})
+	return jobs, err
+}
+
+// Finish moves a delivered job out of the pending bucket.
+func (o *Outbox) Finish(job *DeliveryJob) error {
+	return o.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(pendingBucket).Delete(itob(job.ID)); err != nil {
+			return err
+		}
+		job.Status = JobStatusDelivered
+		job.FinishedAt = o.now()
+		return putJob(tx.Bucket(doneBucket), job)
+	})
+}
+
+// PurgeFinished deletes delivered jobs older than the retention period and
+// returns how many were removed.
+func (o *Outbox) PurgeFinished() (int, error) {
+	cutoff := o.now().Add(-o.retention)
+	purged := 0
+	err := o.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(doneBucket)
+		var expired [][]byte
+		err := bucket.ForEach(func(k, v []byte) error {
+			job, err := decodeJob(k, v)
+			if err != nil {
+				return err
+			}
+			if job.FinishedAt.Before(cutoff) {
+				expired = append(expired, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// Deleting while iterating would make the cursor skip keys
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		purged = len(expired)
+		return nil
+	})
+	return purged, err
+}
+
+func putJob(bucket *bolt.Bucket, job *DeliveryJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode job %d: %w", job.ID, err)
+	}
+	return bucket.Put(itob(job.ID), data)
+}
+
+// itob encodes IDs big-endian so that keys sort in ID order.
+func itob(id uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, id)
+	return b
+}
+
+func btoi(b []byte) uint64 {
+	return binary.BigEndian.Uint64(b)
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/models"
+)
+
+func TestOutbox_AddAndFinish(t *testing.T) {
+	outbox := newTestOutbox(t, "")
+
+	jobs := []*DeliveryJob{
+		{Destination: &config.Destination{Name: "team"}, Feed: &models.WebhookFeed{ID: 8}, Entry: &models.WebhookEntry{ID: 1}},
+		{Destination: &config.Destination{Name: "legacy", WebhookURL: "https://open.feishu.cn/hook/x", Legacy: true}, Feed: &models.WebhookFeed{ID: 8}, Entry: &models.WebhookEntry{ID: 2}},
+		{Destination: &config.Destination{Name: "team"}, Feed: &models.WebhookFeed{ID: 8}, Entry: &models.WebhookEntry{ID: 3}},
+	}
+	if err := outbox.Add(jobs); err != nil {
+		t.Fatalf("Failed to add jobs: %v", err)
+	}
+	if jobs[0].ID != 1 || jobs[2].ID != 3 {
+		t.Errorf("Expected sequential IDs, got %d and %d", jobs[0].ID, jobs[2].ID)
+	}
+
+	pending, err := outbox.PendingAfter(1, 10)
+	if err != nil {
+		t.Fatalf("Failed to list pending jobs: %v", err)
+	}
+	if len(pending) != 2 || pending[0].ID != 2 || pending[1].ID != 3 {
+		t.Fatalf("Expected pending jobs [2 3], got %v", pending)
+	}
+	if pending[0].WebhookRef != webhookRef("https://open.feishu.cn/hook/x") {
+		t.Errorf("Expected a reference to the legacy webhook URL, got %q", pending[0].WebhookRef)
+	}
+	if pending[1].WebhookRef != "" || pending[1].DestinationName != "team" {
+		t.Errorf("Expected configured destination to be stored by name, got %+v", pending[1])
+	}
+	if pending[1].Entry.ID != 3 {
+		t.Errorf("Expected entry 3, got %d", pending[1].Entry.ID)
+	}
+
+	if err := outbox.Finish(jobs[1]); err != nil {
+		t.Fatalf("Failed to finish job: %v", err)
+	}
+	pending, err = outbox.PendingAfter(0, 0)
+	if err != nil {
+		t.Fatalf("Failed to list pending jobs: %v", err)
+	}
+	if len(pending) != 2 || pending[0].ID != 1 || pending[1].ID != 3 {
+		t.Errorf("Expected pending jobs [1 3] after finishing 2, got %v", pending)
+	}
+}
+
+func TestOutbox_PurgeFinished(t *testing.T) {
+	outbox := newTestOutbox(t, "")
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	outbox.now = func() time.Time { return now }
+
+	jobs := newTestJobs(1, 2, 3)
+	if err := outbox.Add(jobs); err != nil {
+		t.Fatalf("Failed to add jobs: %v", err)
+	}
+	if err := outbox.Finish(jobs[0]); err != nil {
+		t.Fatalf("Failed to finish job: %v", err)
+	}
+	now = now.Add(30 * time.Minute)
+	if err := outbox.Finish(jobs[1]); err != nil {
+		t.Fatalf("Failed to finish job: %v", err)
+	}
+
+	// Retention is one hour: only the first job has expired
+	now = now.Add(45 * time.Minute)
+	purged, err := outbox.PurgeFinished()
+	if err != nil {
+		t.Fatalf("Failed to purge: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("Expected 1 purged job, got %d", purged)
+	}
+
+	// Pending jobs are never purged
+	now = now.Add(24 * time.Hour)
+	purged, err = outbox.PurgeFinished()
+	if err != nil {
+		t.Fatalf("Failed to purge: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("Expected 1 purged job, got %d", purged)
+	}
+	pending, err := outbox.PendingAfter(0, 0)
+	if err != nil {
+		t.Fatalf("Failed to list pending jobs: %v", err)
+	}
+	if len(pending) != 1 || pending[0].ID != 3 {
+		t.Errorf("Expected job 3 to stay pending, got %v", pending)
+	}
+}
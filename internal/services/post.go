@@ -0,0 +1,390 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/i18n"
+	"miniflux-feishu/internal/models"
+	"miniflux-feishu/internal/textutil"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// FeishuPost is the rich text body of a "post" message, keyed by locale.
+type FeishuPost map[string]FeishuPostBody
+
+type FeishuPostBody struct {
+	Title string `json:"title"`
+	// Content is a list of paragraphs, each a list of inline elements.
+	Content [][]FeishuPostElement `json:"content"`
+}
+
+// FeishuPostElement is an inline element of a post paragraph: text, a, at or
+// img.
+type FeishuPostElement struct {
+	Tag      string   `json:"tag"`
+	Text     string   `json:"text,omitempty"`
+	Href     string   `json:"href,omitempty"`
+	UserID   string   `json:"user_id,omitempty"`
+	ImageKey string   `json:"image_key,omitempty"`
+	Style    []string `json:"style,omitempty"`
+}
+
+// MarshalJSON sends either the post or the plain fields, since Feishu rejects
+// post content mixed with other keys.
+func (c FeishuTextContent) MarshalJSON() ([]byte, error) {
+	if c.Post != nil {
+		return json.Marshal(struct {
+			Post FeishuPost `json:"post"`
+		}{c.Post})
+	}
+	type plain FeishuTextContent
+	return json.Marshal(plain(c))
+}
+
+func (s *FeishuService) formatEntryPost(entry *models.WebhookEntry, feed *models.WebhookFeed, dest *config.Destination) FeishuMessage {
+	locale := s.localeFor(dest)
+	var metadata entryMetadata
+	var content [][]FeishuPostElement
+	if dest.Metadata {
+		metadata = s.entryMetadata(entry, dest)
+		if byline := metadata.byline(); byline != "" {
+			content = append(content, []FeishuPostElement{{Tag: "text", Text: byline}})
+		}
+		if len(metadata.Hashtags) > 0 {
+			content = append(content, []FeishuPostElement{{Tag: "text", Text: strings.Join(metadata.Hashtags, " ")}})
+		}
+	}
+	content = append(content, htmlToPost(entry.Content, dest.Summary.WithDefaults(config.DefaultSummary), locale)...)
+	for _, e := range describeEnclosures(entry.Enclosures, locale) {
+		content = append(content, e.postParagraph())
+	}
+
+	var links []FeishuPostElement
+	if entry.URL != "" {
+		links = append(links, FeishuPostElement{Tag: "a", Text: locale.T(i18n.OpenArticle), Href: entry.URL})
+	}
+	if metadata.CommentsURL != "" {
+		if len(links) > 0 {
+			links = append(links, FeishuPostElement{Tag: "text", Text: " · "})
+		}
+		links = append(links, FeishuPostElement{Tag: "a", Text: locale.T(i18n.Comments), Href: metadata.CommentsURL})
+	}
+	if len(links) > 0 {
+		content = append(content, links)
+	}
+
+	return FeishuMessage{
+		MsgType: "post",
+		Content: FeishuTextContent{
+			Post: FeishuPost{
+				postLanguage(locale): {
+					Title:   fmt.Sprintf("[%s] - %s", feed.Title, entry.Title),
+					Content: content,
+				},
+			},
+		},
+	}
+}
+
+// postLanguage returns the key Feishu expects for the post body of a locale,
+// such as "en_us" for en-US.
+func postLanguage(locale *i18n.Locale) string {
+	return strings.ToLower(strings.ReplaceAll(locale.Tag, "-", "_"))
+}
+
+// Post text styles.
+const (
+	styleBold        = "bold"
+	styleItalic      = "italic"
+	styleUnderline   = "underline"
+	styleLineThrough = "lineThrough"
+)
+
+// inlineStyles maps inline tags to the post style they turn into.
+var inlineStyles = map[atom.Atom]string{
+	atom.Strong: styleBold,
+	atom.B:      styleBold,
+	atom.H1:     styleBold,
+	atom.H2:     styleBold,
+	atom.H3:     styleBold,
+	atom.H4:     styleBold,
+	atom.H5:     styleBold,
+	atom.H6:     styleBold,
+	atom.Em:     styleItalic,
+	atom.I:      styleItalic,
+	atom.U:      styleUnderline,
+	atom.Ins:    styleUnderline,
+	atom.S:      styleLineThrough,
+	atom.Strike: styleLineThrough,
+	atom.Del:    styleLineThrough,
+}
+
+// postBuilder walks an HTML tree and collects post paragraphs.
+type postBuilder struct {
+	paragraphs [][]FeishuPostElement
+	line       []FeishuPostElement
+	styles     []string
+	href       string
+	pre        int
+	lists      []*listState
+	locale     *i18n.Locale
+	// remaining is the length left for text, in characters or display
+	// columns depending on summary
+	summary   config.SummaryConfig
+	remaining int
+	truncated bool
+}
+
+type listState struct {
+	ordered bool
+	index   int
+}
+
+// htmlToPost converts entry HTML into post paragraphs. Links, emphasis and
+// lists are kept; any other markup is reduced to its text. The text is cut to
+// the summary length, followed by a paragraph with the summary ellipsis.
+// Images without alt text are labelled in the locale.
+func htmlToPost(content string, summary config.SummaryConfig, locale *i18n.Locale) [][]FeishuPostElement {
+	nodes, err := html.ParseFragment(strings.NewReader(content), &html.Node{Type: html.ElementNode, DataAtom: atom.Body, Data: "body"})
+	if err != nil {
+		return [][]FeishuPostElement{{{Tag: "text", Text: content}}}
+	}
+
+	b := &postBuilder{locale: locale, summary: summary, remaining: summary.Length}
+	for _, node := range nodes {
+		b.walk(node)
+	}
+	b.breakLine()
+	if b.truncated {
+		b.paragraphs = append(b.paragraphs, []FeishuPostElement{{Tag: "text", Text: summary.Ellipsis}})
+	}
+	return b.paragraphs
+}
+
+func (b *postBuilder) walk(n *html.Node) {
+	if b.truncated {
+		return
+	}
+
+	switch n.Type {
+	case html.TextNode:
+		b.text(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			b.walk(c)
+		}
+		return
+	}
+
+	if textutil.IsSkippedElement(n.DataAtom) {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		b.breakLine()
+		return
+	case atom.Img:
+		b.image(n)
+		return
+	}
+
+	block := textutil.IsBlockElement(n.DataAtom)
+	if block {
+		b.breakLine()
+	}
+
+	// Restore the inline state once the element is done
+	styles, href := b.styles, b.href
+	defer func() { b.styles, b.href = styles, href }()
+
+	if style, ok := inlineStyles[n.DataAtom]; ok && !slices.Contains(b.styles, style) {
+		b.styles = append(slices.Clip(b.styles), style)
+	}
+
+	switch n.DataAtom {
+	case atom.A:
+		if link := textutil.LinkURL(textutil.Attr(n.Attr, "href"), "mailto"); link != "" {
+			b.href = link
+			before := b.remaining
+			b.children(n)
+			// Links without text still need something to click on
+			if b.remaining == before {
+				b.emit(link)
+			}
+			return
+		}
+	case atom.Pre:
+		b.pre++
+		defer func() { b.pre-- }()
+	case atom.Ul, atom.Ol:
+		b.lists = append(b.lists, &listState{ordered: n.DataAtom == atom.Ol})
+		defer func() { b.lists = b.lists[:len(b.lists)-1] }()
+	case atom.Li:
+		b.listMarker()
+	}
+
+	b.children(n)
+
+	if block {
+		b.breakLine()
+	}
+}
+
+func (b *postBuilder) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.walk(c)
+	}
+}
+
+func (b *postBuilder) text(data string) {
+	if b.pre > 0 {
+		lines := strings.Split(data, "\n")
+		for i, line := range lines {
+			if i > 0 {
+				b.breakLine()
+			}
+			b.emit(line)
+		}
+		return
+	}
+
+	text := textutil.CollapseSpace(data)
+	if b.atLineStart() {
+		text = strings.TrimLeftFunc(text, unicode.IsSpace)
+	}
+	b.emit(text)
+}
+
+// listMarker starts a list item with a bullet or its number, indented by the
+// nesting depth.
+func (b *postBuilder) listMarker() {
+	if len(b.lists) == 0 {
+		return
+	}
+	list := b.lists[len(b.lists)-1]
+	list.index++
+	b.appendElement(FeishuPostElement{Tag: "text", Text: textutil.ListMarker(list.ordered, list.index, len(b.lists))})
+}
+
+// image degrades to a link, since post images need an uploaded image_key.
+func (b *postBuilder) image(n *html.Node) {
+	src := textutil.LinkURL(textutil.Attr(n.Attr, "src"))
+	if src == "" {
+		return
+	}
+	alt := strings.TrimSpace(textutil.Attr(n.Attr, "alt"))
+	if alt == "" {
+		alt = b.locale.T(i18n.Image)
+	}
+	href := b.href
+	b.href = src
+	b.emit("[" + alt + "]")
+	b.href = href
+}
+
+// emit adds text with the current style and link, counting it against the
+// length limit.
+func (b *postBuilder) emit(text string) {
+	if text == "" {
+		return
+	}
+	text = b.fit(text)
+	if text == "" {
+		return
+	}
+
+	element := FeishuPostElement{Tag: "text", Text: text}
+	if b.href != "" {
+		element.Tag = "a"
+		element.Href = b.href
+	}
+	if len(b.styles) > 0 {
+		element.Style = slices.Clone(b.styles)
+	}
+	b.appendElement(element)
+}
+
+// fit returns the part of text that fits the remaining length, without
+// splitting grapheme clusters. Text that is cut ends at its last word boundary
+// when that keeps at least half of it.
+func (b *postBuilder) fit(text string) string {
+	var (
+		state             = -1
+		rest              = text
+		pos, used         int
+		wordPos, wordUsed int
+	)
+	for len(rest) > 0 {
+		cluster, next, boundaries, newState := uniseg.StepString(rest, state)
+		size := 1
+		if b.summary.DisplayWidth {
+			size = boundaries >> uniseg.ShiftWidth
+		}
+		if used+size > b.remaining {
+			if wordUsed*2 >= used {
+				pos, used = wordPos, wordUsed
+			}
+			b.truncated = true
+			b.remaining = 0
+			return strings.TrimRightFunc(text[:pos], unicode.IsSpace)
+		}
+		used += size
+		pos += len(cluster)
+		rest, state = next, newState
+
+		if boundaries&uniseg.MaskWord != 0 {
+			wordPos, wordUsed = pos, used
+		}
+	}
+	b.remaining -= used
+	return text
+}
+
+// appendElement merges the element into the previous one when they only
+// differ in text.
+func (b *postBuilder) appendElement(element FeishuPostElement) {
+	if n := len(b.line); n > 0 {
+		last := &b.line[n-1]
+		if last.Tag == element.Tag && last.Href == element.Href && slices.Equal(last.Style, element.Style) {
+			last.Text += element.Text
+			return
+		}
+	}
+	b.line = append(b.line, element)
+}
+
+// breakLine ends the current paragraph. Empty paragraphs are dropped, so
+// nested blocks do not produce blank lines.
+func (b *postBuilder) breakLine() {
+	for len(b.line) > 0 {
+		last := &b.line[len(b.line)-1]
+		last.Text = strings.TrimRightFunc(last.Text, unicode.IsSpace)
+		if last.Text != "" {
+			break
+		}
+		b.line = b.line[:len(b.line)-1]
+	}
+	if len(b.line) > 0 {
+		b.paragraphs = append(b.paragraphs, b.line)
+	}
+	b.line = nil
+}
+
+func (b *postBuilder) atLineStart() bool {
+	if len(b.line) == 0 {
+		return true
+	}
+	// List markers end with a space too
+	return strings.HasSuffix(b.line[len(b.line)-1].Text, " ")
+}
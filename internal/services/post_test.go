@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/i18n"
+	"miniflux-feishu/internal/models"
+)
+
+func postText(s string, style ...string) FeishuPostElement {
+	return FeishuPostElement{Tag: "text", Text: s, Style: style}
+}
+
+func postLink(s, href string, style ...string) FeishuPostElement {
+	return FeishuPostElement{Tag: "a", Text: s, Href: href, Style: style}
+}
+
+func TestHTMLToPost(t *testing.T) {
+	locale, _ := i18n.Lookup(i18n.DefaultLocale)
+	tests := []struct {
+		name     string
+		input    string
+		expected [][]FeishuPostElement
+	}{
+		{
+			name:     "paragraphs",
+			input:    "<p>First   paragraph</p>\n<p>Second\nparagraph</p>",
+			expected: [][]FeishuPostElement{{postText("First paragraph")}, {postText("Second paragraph")}},
+		},
+		{
+			name:  "link",
+			input: `<p>Read <a href="https://example.org/more">the rest</a>.</p>`,
+			expected: [][]FeishuPostElement{{
+				postText("Read "), postLink("the rest", "https://example.org/more"), postText("."),
+			}},
+		},
+		{
+			name:  "emphasis",
+			input: "<p>This is <strong>bold</strong>, <em>italic</em> and <b><i>both</i></b></p>",
+			expected: [][]FeishuPostElement{{
+				postText("This is "), postText("bold", "bold"), postText(", "), postText("italic", "italic"), postText(" and "), postText("both", "bold", "italic"),
+			}},
+		},
+		{
+			name:  "styled link",
+			input: `<strong><a href="https://example.org">Home</a></strong>`,
+			expected: [][]FeishuPostElement{{
+				postLink("Home", "https://example.org", "bold"),
+			}},
+		},
+		{
+			name:  "unordered list",
+			input: "<ul><li>One</li><li>Two <em>more</em></li></ul>",
+			expected: [][]FeishuPostElement{
+				{postText("• One")},
+				{postText("• Two "), postText("more", "italic")},
+			},
+		},
+		{
+			name:  "nested ordered list",
+			input: "<ol><li>First<ul><li>Inner</li></ul></li><li>Second</li></ol>",
+			expected: [][]FeishuPostElement{
+				{postText("1. First")},
+				{postText("  • Inner")},
+				{postText("2. Second")},
+			},
+		},
+		{
+			name:     "headings are bold",
+			input:    "<h2>Title</h2><p>Body</p>",
+			expected: [][]FeishuPostElement{{postText("Title", "bold")}, {postText("Body")}},
+		},
+		{
+			name:     "line breaks",
+			input:    "Line 1<br>Line 2<br/><br/>Line 3",
+			expected: [][]FeishuPostElement{{postText("Line 1")}, {postText("Line 2")}, {postText("Line 3")}},
+		},
+		{
+			name:     "image becomes a link",
+			input:    `<p><img src="https://example.org/a.png" alt="Chart"></p>`,
+			expected: [][]FeishuPostElement{{postLink("[Chart]", "https://example.org/a.png")}},
+		},
+		{
+			name:     "image without alt text",
+			input:    `<p><img src="https://example.org/a.png"></p>`,
+			expected: [][]FeishuPostElement{{postLink("[image]", "https://example.org/a.png")}},
+		},
+		{
+			name:     "unsafe links are dropped",
+			input:    `<a href="javascript:alert(1)">click</a>`,
+			expected: [][]FeishuPostElement{{postText("click")}},
+		},
+		{
+			name:     "same elements as plain text",
+			input:    `<p>Text<button>Share</button></p><aside>Note</aside><math><mi>x</mi></math>`,
+			expected: [][]FeishuPostElement{{postText("Text")}, {postText("Note")}},
+		},
+		{
+			name:     "link without text",
+			input:    `<a href="https://example.org/x"></a>`,
+			expected: [][]FeishuPostElement{{postLink("https://example.org/x", "https://example.org/x")}},
+		},
+		{
+			name:     "scripts and unknown markup",
+			input:    `<script>alert(1)</script><custom-tag>Kept <span>text</span></custom-tag><style>p{}</style>`,
+			expected: [][]FeishuPostElement{{postText("Kept text")}},
+		},
+		{
+			name:     "preformatted text keeps lines",
+			input:    "<pre>a  b\nc</pre>",
+			expected: [][]FeishuPostElement{{postText("a  b")}, {postText("c")}},
+		},
+		{
+			name:     "broken markup",
+			input:    "<p>Unclosed <strong>bold<p>Next",
+			expected: [][]FeishuPostElement{{postText("Unclosed "), postText("bold", "bold")}, {postText("Next", "bold")}},
+		},
+		{
+			name:     "empty",
+			input:    "",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := htmlToPost(tt.input, config.DefaultSummary, locale)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestHTMLToPost_Truncates(t *testing.T) {
+	locale, _ := i18n.Lookup(i18n.DefaultLocale)
+	tests := []struct {
+		name     string
+		input    string
+		summary  config.SummaryConfig
+		expected [][]FeishuPostElement
+	}{
+		{
+			name:     "characters",
+			input:    "<p>你好世界</p><p>more</p>",
+			summary:  config.SummaryConfig{Length: 3, Ellipsis: "..."},
+			expected: [][]FeishuPostElement{{postText("你好世")}, {postText("...")}},
+		},
+		{
+			name:     "display width and ellipsis",
+			input:    "<p>你好世界</p>",
+			summary:  config.SummaryConfig{Length: 5, Ellipsis: "…", DisplayWidth: true},
+			expected: [][]FeishuPostElement{{postText("你好")}, {postText("…")}},
+		},
+		{
+			name:     "word boundary",
+			input:    "<p>Hello wonderful world</p>",
+			summary:  config.SummaryConfig{Length: 10, Ellipsis: "..."},
+			expected: [][]FeishuPostElement{{postText("Hello")}, {postText("...")}},
+		},
+		{
+			name:     "grapheme clusters",
+			input:    "<p>cafe\u0301s</p>",
+			summary:  config.SummaryConfig{Length: 4, Ellipsis: "..."},
+			expected: [][]FeishuPostElement{{postText("cafe\u0301")}, {postText("...")}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := htmlToPost(tt.input, tt.summary, locale)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestFeishuService_SendEntryToFeishu_Post(t *testing.T) {
+	var capturedBody struct {
+		Content map[string]json.RawMessage `json:"content"`
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&capturedBody); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		w.Write([]byte(`{"code":0,"msg":"success"}`)) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	service := NewFeishuService(testConfig())
+	entry := &models.WebhookEntry{ID: 231, Title: "Example", URL: "https://example.org/article", Content: "<p>Some <b>HTML</b></p>"}
+	feed := &models.WebhookFeed{ID: 8, Title: "Example website"}
+	dest := &config.Destination{Name: "team", WebhookURL: server.URL, Format: config.FormatPost}
+
+	if err := service.SendEntryToFeishu(context.Background(), entry, feed, dest); err != nil {
+		t.Fatalf("Failed to send entry to Feishu: %v", err)
+	}
+
+	content := capturedBody.Content
+	if len(content) != 1 {
+		t.Fatalf("Expected only the post key in content, got %v", content)
+	}
+	var post FeishuPost
+	if err := json.Unmarshal(content["post"], &post); err != nil {
+		t.Fatalf("Failed to decode post: %v", err)
+	}
+	body := post["en_us"]
+	if body.Title != "[Example website] - Example" {
+		t.Errorf("Expected title '[Example website] - Example', got %q", body.Title)
+	}
+	expected := [][]FeishuPostElement{
+		{postText("Some "), postText("HTML", "bold")},
+		{postLink("Open article", "https://example.org/article")},
+	}
+	if !reflect.DeepEqual(body.Content, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, body.Content)
+	}
+}
+
+func TestFeishuService_FormatEntryPost_Locale(t *testing.T) {
+	service := NewFeishuService(testConfig())
+	entry := &models.WebhookEntry{Title: "Example", Content: `<p><img src="https://example.org/a.png"></p>`}
+	feed := &models.WebhookFeed{Title: "Example website"}
+
+	tests := []struct {
+		locale   string
+		key      string
+		imageAlt string
+	}{
+		{locale: "", key: "en_us", imageAlt: "[image]"},
+		{locale: "zh-CN", key: "zh_cn", imageAlt: "[图片]"},
+	}
+
+	for _, tt := range tests {
+		message := service.formatEntryPost(entry, feed, &config.Destination{Format: config.FormatPost, Locale: tt.locale})
+		body, ok := message.Content.Post[tt.key]
+		if !ok || len(message.Content.Post) != 1 {
+			t.Errorf("Expected only the %s body, got %+v", tt.key, message.Content.Post)
+			continue
+		}
+		if text := body.Content[0][0].Text; text != tt.imageAlt {
+			t.Errorf("Expected image link %q, got %q", tt.imageAlt, text)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"miniflux-feishu/internal/config"
+)
+
+// tokenBucket hands out reservations instead of rejecting requests: once the
+// bucket is empty the token count goes negative and every caller is told how
+// long to wait for its turn, which spreads a burst out evenly.
+type tokenBucket struct {
+	capacity float64
+	rate     float64 // tokens per second
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(limit int, per time.Duration, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		capacity: float64(limit),
+		rate:     float64(limit) / per.Seconds(),
+		tokens:   float64(limit),
+		last:     now,
+	}
+}
+
+// reserve takes one token and returns how long the caller has to wait before
+// using it.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.rate
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+		b.last = now
+	}
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// full reports whether the bucket has refilled completely, in which case it
+// behaves like a new one.
+func (b *tokenBucket) full(now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.capacity
+}
+
+// cancel returns a token taken by a reservation that was not used.
+func (b *tokenBucket) cancel() {
+	b.tokens++
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+}
+
+// botLimiter enforces both the per-second and the per-minute quota of a bot.
+type botLimiter struct {
+	mu        sync.Mutex
+	perSecond *tokenBucket
+	perMinute *tokenBucket
+}
+
+// rateLimiter keeps one botLimiter per webhook URL, since Feishu applies its
+// quota per bot. Limiters that have refilled are dropped when a new one is
+// created, so the map only holds bots that were used recently.
+type rateLimiter struct {
+	mu       sync.Mutex
+	limiters map[string]*botLimiter
+	now      func() time.Time
+	sleep    func(ctx context.Context, d time.Duration) error
+}
+
+func newRateLimiter(now func() time.Time, sleep func(ctx context.Context, d time.Duration) error) *rateLimiter {
+	return &rateLimiter{
+		limiters: make(map[string]*botLimiter),
+		now:      now,
+		sleep:    sleep,
+	}
+}
+
+// Wait blocks until a message may be sent to the destination and returns the
+// time spent waiting.
+func (r *rateLimiter) Wait(ctx context.Context, dest *config.Destination) (time.Duration, error) {
+	limit := dest.RateLimit.WithDefaults(config.DefaultRateLimit)
+	limiter, delay := r.reserve(dest.WebhookURL, limit)
+
+	if delay <= 0 {
+		return 0, nil
+	}
+	if err := r.sleep(ctx, delay); err != nil {
+		limiter.mu.Lock()
+		limiter.perSecond.cancel()
+		limiter.perMinute.cancel()
+		limiter.mu.Unlock()
+		return 0, err
+	}
+	return delay, nil
+}
+
+// reserve takes a token from the limiter of key and returns the limiter and
+// how long to wait. The reservation is made while holding r.mu so that the
+// limiter cannot be reaped in between.
+func (r *rateLimiter) reserve(key string, limit config.RateLimit) (*botLimiter, time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	limiter, ok := r.limiters[key]
+	if !ok {
+		r.reap(now)
+		limiter = &botLimiter{
+			perSecond: newTokenBucket(limit.PerSecond, time.Second, now),
+			perMinute: newTokenBucket(limit.PerMinute, time.Minute, now),
+		}
+		r.limiters[key] = limiter
+	}
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	return limiter, max(limiter.perSecond.reserve(now), limiter.perMinute.reserve(now))
+}
+
+// reap drops the limiters whose buckets are full again. Callers hold r.mu.
+func (r *rateLimiter) reap(now time.Time) {
+	for key, limiter := range r.limiters {
+		limiter.mu.Lock()
+		idle := limiter.perSecond.full(now) && limiter.perMinute.full(now)
+		limiter.mu.Unlock()
+		if idle {
+			delete(r.limiters, key)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/models"
+)
+
+// fakeClock advances only when the rate limiter sleeps
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+	return ctx.Err()
+}
+
+func TestRateLimiter_SpreadsBurstPerSecond(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	limiter := newRateLimiter(clock.Now, clock.Sleep)
+	dest := &config.Destination{Name: "team", WebhookURL: "https://open.feishu.cn/hook/a", RateLimit: config.RateLimit{PerSecond: 5, PerMinute: 100}}
+
+	start := clock.now
+	for i := 0; i < 10; i++ {
+		if _, err := limiter.Wait(context.Background(), dest); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	// The first 5 go out immediately, the next 5 one every 200ms
+	if elapsed := clock.now.Sub(start); elapsed != time.Second {
+		t.Errorf("Expected the burst of 10 to take 1s, took %s", elapsed)
+	}
+	if len(clock.sleeps) != 5 {
+		t.Errorf("Expected 5 waits, got %v", clock.sleeps)
+	}
+}
+
+func TestRateLimiter_EnforcesPerMinuteQuota(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	limiter := newRateLimiter(clock.Now, clock.Sleep)
+	dest := &config.Destination{Name: "team", WebhookURL: "https://open.feishu.cn/hook/a", RateLimit: config.RateLimit{PerSecond: 100, PerMinute: 10}}
+
+	var total time.Duration
+	for i := 0; i < 11; i++ {
+		waited, err := limiter.Wait(context.Background(), dest)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		total += waited
+	}
+
+	// The 11th message has to wait for one token of the per-minute bucket
+	if total != 6*time.Second {
+		t.Errorf("Expected a 6s wait for the 11th message, got %s", total)
+	}
+}
+
+func TestRateLimiter_KeyedByBot(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	limiter := newRateLimiter(clock.Now, clock.Sleep)
+	limit := config.RateLimit{PerSecond: 1, PerMinute: 100}
+	first := &config.Destination{Name: "first", WebhookURL: "https://open.feishu.cn/hook/a", RateLimit: limit}
+	second := &config.Destination{Name: "second", WebhookURL: "https://open.feishu.cn/hook/b", RateLimit: limit}
+
+	for _, dest := range []*config.Destination{first, second} {
+		if waited, _ := limiter.Wait(context.Background(), dest); waited != 0 {
+			t.Errorf("Expected no wait for the first message to %s, got %s", dest.Name, waited)
+		}
+	}
+}
+
+func TestRateLimiter_ReapsIdleLimiters(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	limiter := newRateLimiter(clock.Now, clock.Sleep)
+	limit := config.RateLimit{PerSecond: 1, PerMinute: 60}
+	first := &config.Destination{Name: "first", WebhookURL: "https://open.feishu.cn/hook/a", RateLimit: limit}
+	second := &config.Destination{Name: "second", WebhookURL: "https://open.feishu.cn/hook/b", RateLimit: limit}
+
+	limiter.Wait(context.Background(), first)  //nolint:errcheck
+	limiter.Wait(context.Background(), second) //nolint:errcheck
+	if len(limiter.limiters) != 2 {
+		t.Fatalf("Expected 2 limiters, got %d", len(limiter.limiters))
+	}
+
+	// Both buckets of the first bot refill after a second
+	clock.now = clock.now.Add(time.Second)
+	third := &config.Destination{Name: "third", WebhookURL: "https://open.feishu.cn/hook/c", RateLimit: limit}
+	limiter.Wait(context.Background(), third) //nolint:errcheck
+	if _, ok := limiter.limiters[first.WebhookURL]; ok {
+		t.Error("Expected the idle limiter to be reaped")
+	}
+	if len(limiter.limiters) != 1 {
+		t.Errorf("Expected only the new limiter to remain, got %d", len(limiter.limiters))
+	}
+}
+
+func TestRateLimiter_CancelledWaitReturnsToken(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	limiter := newRateLimiter(clock.Now, func(context.Context, time.Duration) error {
+		return context.Canceled
+	})
+	dest := &config.Destination{Name: "team", WebhookURL: "https://open.feishu.cn/hook/a", RateLimit: config.RateLimit{PerSecond: 1, PerMinute: 100}}
+
+	if _, err := limiter.Wait(context.Background(), dest); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := limiter.Wait(context.Background(), dest); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	// Once the bucket refills, the cancelled reservation must not delay anyone
+	clock.now = clock.now.Add(time.Second)
+	limiter.sleep = clock.Sleep
+	if waited, _ := limiter.Wait(context.Background(), dest); waited != 0 {
+		t.Errorf("Expected no wait after refill, got %s", waited)
+	}
+}
+
+func TestFeishuService_SendEntryToFeishu_RecordsRateLimitWait(t *testing.T) {
+	server, calls := newScriptedServer(t, scriptedResponse{status: http.StatusOK, body: `{"code":0}`})
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	service := NewFeishuService(testConfig())
+	service.now = clock.Now
+	service.sleep = clock.Sleep
+
+	dest := &config.Destination{Name: "ratelimit-test", WebhookURL: server.URL, RateLimit: config.RateLimit{PerSecond: 1, PerMinute: 100}}
+	feed := &models.WebhookFeed{ID: 8, Title: "Example website"}
+	for i := 0; i < 3; i++ {
+		entry := &models.WebhookEntry{ID: int64(i), Title: "Example"}
+		if err := service.SendEntryToFeishu(context.Background(), entry, feed, dest); err != nil {
+			t.Fatalf("Failed to send entry: %v", err)
+		}
+	}
+
+	if *calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", *calls)
+	}
+	if waited := rateLimitWaitMetrics.Get("ratelimit-test").String(); waited != "2" {
+		t.Errorf("Expected 2 seconds of waiting to be recorded, got %s", waited)
+	}
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/models"
+)
+
+type scriptedResponse struct {
+	status     int
+	body       string
+	retryAfter string
+}
+
+// newScriptedServer answers requests with the given responses in order and
+// repeats the last one once the script runs out.
+func newScriptedServer(t *testing.T, responses ...scriptedResponse) (*httptest.Server, *int) {
+	t.Helper()
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := responses[min(calls, len(responses)-1)]
+		calls++
+		if resp.retryAfter != "" {
+			w.Header().Set("Retry-After", resp.retryAfter)
+		}
+		w.WriteHeader(resp.status)
+		w.Write([]byte(resp.body)) //nolint:errcheck
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+// newRetryTestService records backoff delays instead of sleeping.
+func newRetryTestService(delays *[]time.Duration) *FeishuService {
+	service := NewFeishuService(testConfig())
+	service.sleep = func(ctx context.Context, d time.Duration) error {
+		*delays = append(*delays, d)
+		return ctx.Err()
+	}
+	return service
+}
+
+func sendTestEntry(service *FeishuService, webhookURL string, policy config.RetryPolicy) error {
+	entry := &models.WebhookEntry{ID: 231, Title: "Example", URL: "https://example.org/article"}
+	feed := &models.WebhookFeed{ID: 8, Title: "Example website"}
+	dest := &config.Destination{Name: "team", WebhookURL: webhookURL, Retry: policy}
+	return service.SendEntryToFeishu(context.Background(), entry, feed, dest)
+}
+
+func TestFeishuService_SendEntryToFeishu_Retry(t *testing.T) {
+	policy := config.RetryPolicy{MaxAttempts: 4, InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}
+
+	tests := []struct {
+		name          string
+		responses     []scriptedResponse
+		expectedCalls int
+		expectError   bool
+		retryable     bool
+	}{
+		{
+			name: "server error then success",
+			responses: []scriptedResponse{
+				{status: http.StatusBadGateway, body: "bad gateway"},
+				{status: http.StatusOK, body: `{"code":0}`},
+			},
+			expectedCalls: 2,
+		},
+		{
+			name: "rate limited by status",
+			responses: []scriptedResponse{
+				{status: http.StatusTooManyRequests},
+				{status: http.StatusOK, body: `{"code":0}`},
+			},
+			expectedCalls: 2,
+		},
+		{
+			name: "rate limited by feishu code",
+			responses: []scriptedResponse{
+				{status: http.StatusBadRequest, body: `{"code":9499,"msg":"too many request"}`},
+				{status: http.StatusBadRequest, body: `{"code":11232,"msg":"frequency limited"}`},
+				{status: http.StatusOK, body: `{"code":0}`},
+			},
+			expectedCalls: 3,
+		},
+		{
+			name:          "bad request is permanent",
+			responses:     []scriptedResponse{{status: http.StatusBadRequest, body: `{"code":9499,"msg":"Bad Request"}`}},
+			expectedCalls: 1,
+			expectError:   true,
+		},
+		{
+			name:          "invalid webhook is permanent",
+			responses:     []scriptedResponse{{status: http.StatusBadRequest, body: `{"code":19001,"msg":"param invalid: incoming webhook access token invalid"}`}},
+			expectedCalls: 1,
+			expectError:   true,
+		},
+		{
+			name:          "gives up after max attempts",
+			responses:     []scriptedResponse{{status: http.StatusServiceUnavailable}},
+			expectedCalls: 4,
+			expectError:   true,
+			retryable:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, calls := newScriptedServer(t, tt.responses...)
+			var delays []time.Duration
+			service := newRetryTestService(&delays)
+
+			err := sendTestEntry(service, server.URL, policy)
+
+			if *calls != tt.expectedCalls {
+				t.Errorf("Expected %d calls, got %d", tt.expectedCalls, *calls)
+			}
+			if len(delays) != tt.expectedCalls-1 {
+				t.Errorf("Expected %d backoff delays, got %v", tt.expectedCalls-1, delays)
+			}
+			if !tt.expectError {
+				if err != nil {
+					t.Errorf("Expected success, got %v", err)
+				}
+				return
+			}
+
+			var sendErr *SendError
+			if !errors.As(err, &sendErr) {
+				t.Fatalf("Expected a SendError, got %v", err)
+			}
+			if sendErr.Retryable != tt.retryable {
+				t.Errorf("Expected Retryable %v, got %v", tt.retryable, sendErr.Retryable)
+			}
+
+			var deliveryErr *DeliveryError
+			if !errors.As(err, &deliveryErr) {
+				t.Fatalf("Expected a DeliveryError, got %v", err)
+			}
+			if len(deliveryErr.Attempts) != tt.expectedCalls {
+				t.Errorf("Expected %d recorded attempts, got %d", tt.expectedCalls, len(deliveryErr.Attempts))
+			}
+			if last := deliveryErr.Attempts[len(deliveryErr.Attempts)-1]; last.StatusCode != sendErr.StatusCode || last.Kind != ErrorKind(sendErr) {
+				t.Errorf("Expected last attempt to match %v, got %+v", sendErr, last)
+			}
+		})
+	}
+}
+
+func TestFeishuService_SendEntryToFeishu_HonoursRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxBackoff time.Duration
+		expected   time.Duration
+	}{
+		{name: "within max backoff", maxBackoff: time.Minute, expected: 42 * time.Second},
+		{name: "clamped to max backoff", maxBackoff: 10 * time.Second, expected: 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := newScriptedServer(t,
+				scriptedResponse{status: http.StatusTooManyRequests, retryAfter: "42"},
+				scriptedResponse{status: http.StatusOK, body: `{"code":0}`},
+			)
+			var delays []time.Duration
+			service := newRetryTestService(&delays)
+
+			policy := config.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second, MaxBackoff: tt.maxBackoff}
+			if err := sendTestEntry(service, server.URL, policy); err != nil {
+				t.Fatalf("Expected success, got %v", err)
+			}
+
+			if len(delays) != 1 || delays[0] != tt.expected {
+				t.Errorf("Expected a single %s delay, got %v", tt.expected, delays)
+			}
+		})
+	}
+}
+
+func TestFeishuService_SendEntryToFeishu_StopsWhenCancelled(t *testing.T) {
+	server, calls := newScriptedServer(t, scriptedResponse{status: http.StatusInternalServerError})
+	service := NewFeishuService(testConfig())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	service.sleep = func(context.Context, time.Duration) error {
+		cancel()
+		return context.Canceled
+	}
+
+	entry := &models.WebhookEntry{ID: 231}
+	feed := &models.WebhookFeed{ID: 8}
+	dest := &config.Destination{WebhookURL: server.URL, Retry: config.RetryPolicy{MaxAttempts: 5}}
+	err := service.SendEntryToFeishu(ctx, entry, feed, dest)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if *calls != 1 {
+		t.Errorf("Expected 1 call before cancellation, got %d", *calls)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := config.RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	tests := []struct {
+		retry   int
+		ceiling time.Duration
+	}{
+		{retry: 1, ceiling: time.Second},
+		{retry: 2, ceiling: 2 * time.Second},
+		{retry: 3, ceiling: 4 * time.Second},
+		{retry: 4, ceiling: 5 * time.Second},
+		{retry: 30, ceiling: 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			delay := backoff(policy, tt.retry)
+			if delay < tt.ceiling/2 || delay > tt.ceiling {
+				t.Errorf("Retry %d: expected delay in [%s, %s], got %s", tt.retry, tt.ceiling/2, tt.ceiling, delay)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{value: "", expected: 0},
+		{value: "120", expected: 2 * time.Minute},
+		{value: "Tue, 02 Jan 2024 15:05:05 GMT", expected: time.Minute},
+		{value: "Tue, 02 Jan 2024 15:00:00 GMT", expected: 0},
+		{value: "soon", expected: 0},
+	}
+
+	for _, tt := range tests {
+		if result := parseRetryAfter(tt.value, now); result != tt.expected {
+			t.Errorf("parseRetryAfter(%q): expected %s, got %s", tt.value, tt.expected, result)
+		}
+	}
+}
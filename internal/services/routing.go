@@ -0,0 +1,141 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/models"
+)
+
+// EntryRouter picks the destinations of an entry from the routing rules.
+type EntryRouter struct {
+	rules    []route
+	fallback *config.Destination
+}
+
+type route struct {
+	name         string
+	match        config.RouteMatch
+	title        *regexp.Regexp
+	destinations []*config.Destination
+}
+
+// NewEntryRouter compiles the routing rules of the configuration.
+func NewEntryRouter(cfg *config.Config) (*EntryRouter, error) {
+	r := &EntryRouter{}
+	for i, rule := range cfg.Routing.Rules {
+		rt := route{name: rule.Name, match: rule.Match}
+		if rt.name == "" {
+			rt.name = fmt.Sprintf("rule %d", i)
+		}
+		if rule.Match.Title != "" {
+			title, err := regexp.Compile(rule.Match.Title)
+			if err != nil {
+				return nil, fmt.Errorf("routing rule %q: %w", rt.name, err)
+			}
+			rt.title = title
+		}
+		for _, name := range rule.Destinations {
+			dest, ok := cfg.Destinations[name]
+			if !ok {
+				return nil, fmt.Errorf("routing rule %q: unknown destination %q", rt.name, name)
+			}
+			rt.destinations = append(rt.destinations, dest)
+		}
+		r.rules = append(r.rules, rt)
+	}
+	if name := cfg.Routing.Fallback; name != "" {
+		dest, ok := cfg.Destinations[name]
+		if !ok {
+			return nil, fmt.Errorf("routing fallback: unknown destination %q", name)
+		}
+		r.fallback = dest
+	}
+	return r, nil
+}
+
+// Route returns the destinations of every rule matching the entry, each once
+// and in rule order. Entries no rule matches go to the fallback, or nowhere
+// when there is none.
+func (r *EntryRouter) Route(entry *models.WebhookEntry, feed *models.WebhookFeed) []*config.Destination {
+	var dests []*config.Destination
+	seen := make(map[*config.Destination]bool)
+	for _, rt := range r.rules {
+		if !rt.matches(entry, feed) {
+			continue
+		}
+		for _, dest := range rt.destinations {
+			if !seen[dest] {
+				seen[dest] = true
+				dests = append(dests, dest)
+			}
+		}
+	}
+	if len(dests) == 0 && r.fallback != nil {
+		dests = append(dests, r.fallback)
+	}
+	return dests
+}
+
+func (rt *route) matches(entry *models.WebhookEntry, feed *models.WebhookFeed) bool {
+	m := rt.match
+	if len(m.FeedIDs) > 0 && !slices.Contains(m.FeedIDs, feed.ID) {
+		return false
+	}
+	if len(m.Categories) > 0 && (feed.Category == nil || !containsFold(m.Categories, feed.Category.Title)) {
+		return false
+	}
+	if len(m.FeedHosts) > 0 && !matchesHost(m.FeedHosts, feed.FeedURL) {
+		return false
+	}
+	if len(m.Tags) > 0 && !anyTag(m.Tags, entry.Tags) {
+		return false
+	}
+	if rt.title != nil && !rt.title.MatchString(entry.Title) {
+		return false
+	}
+	return true
+}
+
+// containsFold reports whether s is in values, ignoring case.
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(strings.TrimSpace(v), strings.TrimSpace(s)) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyTag(want, tags []string) bool {
+	for _, tag := range tags {
+		if containsFold(want, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesHost reports whether the host of feedURL is one of hosts or a
+// subdomain of one.
+func matchesHost(hosts []string, feedURL string) bool {
+	u, err := url.Parse(feedURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return false
+	}
+	for _, h := range hosts {
+		h = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(h), "."))
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
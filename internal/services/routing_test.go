@@ -0,0 +1,117 @@
+package services
+
+import (
+	"slices"
+	"testing"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/models"
+)
+
+func TestEntryRouter_Route(t *testing.T) {
+	cfg := &config.Config{
+		Destinations: map[string]*config.Destination{
+			"news":     {Name: "news"},
+			"releases": {Name: "releases"},
+			"go":       {Name: "go"},
+			"inbox":    {Name: "inbox"},
+		},
+		Routing: config.RoutingConfig{
+			Rules: []config.RoutingRule{
+				{Name: "feeds", Match: config.RouteMatch{FeedIDs: []int64{1, 2}}, Destinations: []string{"news"}},
+				{Name: "github", Match: config.RouteMatch{FeedHosts: []string{"github.com"}, Title: `(?i)^release`}, Destinations: []string{"releases"}},
+				{Name: "golang", Match: config.RouteMatch{Categories: []string{"Go"}}, Destinations: []string{"go", "news"}},
+				{Name: "tags", Match: config.RouteMatch{Tags: []string{"golang"}}, Destinations: []string{"go"}},
+			},
+			Fallback: "inbox",
+		},
+	}
+	router, err := NewEntryRouter(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		feed     *models.WebhookFeed
+		entry    *models.WebhookEntry
+		expected []string
+	}{
+		{
+			name:     "feed id",
+			feed:     &models.WebhookFeed{ID: 2},
+			entry:    &models.WebhookEntry{Title: "Hello"},
+			expected: []string{"news"},
+		},
+		{
+			name:     "host and title",
+			feed:     &models.WebhookFeed{ID: 5, FeedURL: "https://github.com/golang/go/releases.atom"},
+			entry:    &models.WebhookEntry{Title: "Release go1.22"},
+			expected: []string{"releases"},
+		},
+		{
+			name:     "subdomain",
+			feed:     &models.WebhookFeed{ID: 5, FeedURL: "https://api.GitHub.com/feed"},
+			entry:    &models.WebhookEntry{Title: "release 2"},
+			expected: []string{"releases"},
+		},
+		{
+			name:     "host without matching title",
+			feed:     &models.WebhookFeed{ID: 5, FeedURL: "https://github.com/golang/go/commits.atom"},
+			entry:    &models.WebhookEntry{Title: "Fix typo"},
+			expected: []string{"inbox"},
+		},
+		{
+			name:     "lookalike host",
+			feed:     &models.WebhookFeed{ID: 5, FeedURL: "https://notgithub.com/feed"},
+			entry:    &models.WebhookEntry{Title: "Release 1"},
+			expected: []string{"inbox"},
+		},
+		{
+			name:     "category case insensitive",
+			feed:     &models.WebhookFeed{ID: 5, Category: &models.WebhookCategory{Title: "go"}},
+			entry:    &models.WebhookEntry{Title: "Hello"},
+			expected: []string{"go", "news"},
+		},
+		{
+			name:     "several rules without duplicates",
+			feed:     &models.WebhookFeed{ID: 1, Category: &models.WebhookCategory{Title: "Go"}},
+			entry:    &models.WebhookEntry{Title: "Hello", Tags: []string{"Golang"}},
+			expected: []string{"news", "go"},
+		},
+		{
+			name:     "fallback",
+			feed:     &models.WebhookFeed{ID: 5},
+			entry:    &models.WebhookEntry{Title: "Hello", Tags: []string{"rust"}},
+			expected: []string{"inbox"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var names []string
+			for _, dest := range router.Route(tt.entry, tt.feed) {
+				names = append(names, dest.Name)
+			}
+			if !slices.Equal(names, tt.expected) {
+				t.Errorf("Expected destinations %v, got %v", tt.expected, names)
+			}
+		})
+	}
+}
+
+func TestEntryRouter_Route_NoFallback(t *testing.T) {
+	cfg := &config.Config{
+		Destinations: map[string]*config.Destination{"news": {Name: "news"}},
+		Routing: config.RoutingConfig{Rules: []config.RoutingRule{
+			{Match: config.RouteMatch{FeedIDs: []int64{1}}, Destinations: []string{"news"}},
+		}},
+	}
+	router, err := NewEntryRouter(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+	if dests := router.Route(&models.WebhookEntry{}, &models.WebhookFeed{ID: 2}); len(dests) != 0 {
+		t.Errorf("Expected no destinations, got %d", len(dests))
+	}
+}
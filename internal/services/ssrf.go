@@ -0,0 +1,96 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"miniflux-feishu/internal/config"
+)
+
+// ErrWebhookURLNotAllowed is returned for webhook targets rejected by the
+// outbound security policy.
+var ErrWebhookURLNotAllowed = errors.New("webhook URL not allowed")
+
+// carrierGradeNAT is 100.64.0.0/10, which net.IP.IsPrivate does not cover.
+var carrierGradeNAT = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isBlockedIP reports whether ip points at the host itself, a private network
+// or a link-local range such as the 169.254.169.254 cloud metadata endpoint.
+func isBlockedIP(ip net.IP) bool {
+	return ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		ip.IsUnspecified() ||
+		carrierGradeNAT.Contains(ip)
+}
+
+// ValidateWebhookURL checks a webhook target against the host allowlist. IP
+// literals are checked here as well, hostnames are checked again at dial time
+// once they have been resolved.
+func (s *FeishuService) ValidateWebhookURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrWebhookURLNotAllowed, err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Errorf("%w: unsupported scheme %q", ErrWebhookURLNotAllowed, u.Scheme)
+	}
+	host := u.Hostname()
+	if !s.security.HostAllowed(host) {
+		return fmt.Errorf("%w: host %q is not in the allowlist", ErrWebhookURLNotAllowed, host)
+	}
+	if ip := net.ParseIP(host); ip != nil && !s.security.AllowPrivateNetworks && isBlockedIP(ip) {
+		return fmt.Errorf("%w: address %s is not routable", ErrWebhookURLNotAllowed, ip)
+	}
+	return nil
+}
+
+// newGuardedClient returns an HTTP client that refuses to connect to blocked
+// addresses. The check runs on the resolved IP right before connecting, so a
+// DNS record that changes after ValidateWebhookURL cannot bypass it.
+func newGuardedClient(security config.SecurityConfig, validate func(string) error) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if !security.AllowPrivateNetworks {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isBlockedIP(ip) {
+				return fmt.Errorf("%w: address %s is not routable", ErrWebhookURLNotAllowed, host)
+			}
+			return nil
+		}
+	}
+
+	return &http.Client{
+		Timeout: 30 * time.Second,
+		// Proxy is deliberately left unset, a proxy would hide the real target
+		// from the dial-time check
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("stopped after 5 redirects")
+			}
+			return validate(req.URL.String())
+		},
+	}
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/models"
+)
+
+func TestFeishuService_ValidateWebhookURL(t *testing.T) {
+	service := NewFeishuService(&config.Config{})
+
+	tests := []struct {
+		name       string
+		webhookURL string
+		allowed    bool
+	}{
+		{name: "feishu", webhookURL: "https://open.feishu.cn/open-apis/bot/v2/hook/token", allowed: true},
+		{name: "lark", webhookURL: "https://open.larksuite.com/open-apis/bot/v2/hook/token", allowed: true},
+		{name: "host case is ignored", webhookURL: "https://Open.Feishu.CN/open-apis/bot/v2/hook/token", allowed: true},
+		{name: "other host", webhookURL: "https://hooks.example.com/webhook"},
+		{name: "lookalike host", webhookURL: "https://open.feishu.cn.example.com/hook"},
+		{name: "metadata address", webhookURL: "http://169.254.169.254/latest/meta-data/"},
+		{name: "unsupported scheme", webhookURL: "gopher://open.feishu.cn/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.ValidateWebhookURL(tt.webhookURL)
+			if tt.allowed && err != nil {
+				t.Errorf("Expected %s to be allowed, got %v", tt.webhookURL, err)
+			}
+			if !tt.allowed && !errors.Is(err, ErrWebhookURLNotAllowed) {
+				t.Errorf("Expected ErrWebhookURLNotAllowed for %s, got %v", tt.webhookURL, err)
+			}
+		})
+	}
+}
+
+func TestFeishuService_ValidateWebhookURL_PrivateAddresses(t *testing.T) {
+	cfg := &config.Config{Security: config.SecurityConfig{AllowedHosts: []string{"*"}}}
+	service := NewFeishuService(cfg)
+
+	for _, webhookURL := range []string{
+		"http://127.0.0.1/hook",
+		"http://10.0.0.5/hook",
+		"http://192.168.1.1/hook",
+		"http://100.100.100.200/latest/meta-data/",
+		"http://[::1]/hook",
+		"http://[fd00:ec2::254]/hook",
+		"http://0.0.0.0/hook",
+	} {
+		if err := service.ValidateWebhookURL(webhookURL); !errors.Is(err, ErrWebhookURLNotAllowed) {
+			t.Errorf("Expected ErrWebhookURLNotAllowed for %s, got %v", webhookURL, err)
+		}
+	}
+}
+
+func TestFeishuService_BlocksPrivateAddressAtDialTime(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// "localhost" passes the allowlist but resolves to a loopback address,
+	// just like a rebinding DNS record would
+	cfg := &config.Config{Security: config.SecurityConfig{AllowedHosts: []string{"localhost"}}}
+	service := NewFeishuService(cfg)
+
+	webhookURL := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	entry := &models.WebhookEntry{ID: 231, Title: "Example"}
+	feed := &models.WebhookFeed{ID: 8, Title: "Example website"}
+
+	err := service.SendEntryToFeishu(context.Background(), entry, feed, &config.Destination{WebhookURL: webhookURL})
+	if !errors.Is(err, ErrWebhookURLNotAllowed) {
+		t.Errorf("Expected ErrWebhookURLNotAllowed, got %v", err)
+	}
+	if called {
+		t.Errorf("Expected the request not to reach the server")
+	}
+}
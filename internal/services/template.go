@@ -0,0 +1,86 @@
+package services
+
+import (
+	"encoding/json"
+	"text/template"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/models"
+	"miniflux-feishu/internal/templates"
+)
+
+// msgTypes maps destination formats to the Feishu msg_type.
+var msgTypes = map[string]string{
+	"":                "text",
+	config.FormatText: "text",
+	config.FormatPost: "post",
+	config.FormatCard: "interactive",
+}
+
+// templateCache keeps the compiled templates of the configured destinations,
+// keyed by their source so that destinations built from the outbox share
+// them. It is filled once, so templates tried in the preview are parsed on
+// every call instead of piling up.
+type templateCache struct {
+	templates map[string]*template.Template
+}
+
+func newTemplateCache(cfg *config.Config) *templateCache {
+	c := &templateCache{templates: make(map[string]*template.Template)}
+	for name, dest := range cfg.Destinations {
+		if dest.Template == "" {
+			continue
+		}
+		// Load has validated the templates; a broken one fails when used
+		if tmpl, err := templates.Parse(name, dest.Template); err == nil {
+			c.templates[dest.Template] = tmpl
+		}
+	}
+	return c
+}
+
+func (c *templateCache) get(dest *config.Destination) (*template.Template, error) {
+	if tmpl, ok := c.templates[dest.Template]; ok {
+		return tmpl, nil
+	}
+	return templates.Parse(dest.Name, dest.Template)
+}
+
+func (s *FeishuService) formatTemplate(entry *models.WebhookEntry, feed *models.WebhookFeed, dest *config.Destination) (FeishuMessage, error) {
+	tmpl, err := s.templates.get(dest)
+	if err != nil {
+		return FeishuMessage{}, err
+	}
+	rendered, err := templates.Render(tmpl, entry, feed)
+	if err != nil {
+		return FeishuMessage{}, err
+	}
+	return FeishuMessage{MsgType: msgTypes[dest.Format], Rendered: rendered}, nil
+}
+
+// MarshalJSON puts the output of a template where the message type expects
+// its body.
+func (m FeishuMessage) MarshalJSON() ([]byte, error) {
+	type plain FeishuMessage
+	if m.Rendered == nil {
+		return json.Marshal(plain(m))
+	}
+
+	out := struct {
+		Timestamp string          `json:"timestamp,omitempty"`
+		Sign      string          `json:"sign,omitempty"`
+		MsgType   string          `json:"msg_type"`
+		Content   json.RawMessage `json:"content,omitempty"`
+		Card      json.RawMessage `json:"card,omitempty"`
+	}{
+		Timestamp: m.Timestamp,
+		Sign:      m.Sign,
+		MsgType:   m.MsgType,
+	}
+	if m.MsgType == "interactive" {
+		out.Card = m.Rendered
+	} else {
+		out.Content = m.Rendered
+	}
+	return json.Marshal(out)
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"miniflux-feishu/internal/config"
+	"miniflux-feishu/internal/models"
+)
+
+func TestFeishuService_SendEntryToFeishu_Template(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		template string
+		expected string
+	}{
+		{
+			name:     "text",
+			template: `{"title": {{json .Entry.Title}}, "content": {{json .Feed.Title}}, "url": {{json .Entry.URL}}}`,
+			expected: `{"msg_type":"text","content":{"title":"Example","content":"Example website","url":"https://example.org/article"}}`,
+		},
+		{
+			name:     "post",
+			format:   config.FormatPost,
+			template: `{"post": {"en_us": {"title": {{json .Entry.Title}}, "content": [[{"tag": "text", "text": "hi"}]]}}}`,
+			expected: `{"msg_type":"post","content":{"post":{"en_us":{"title":"Example","content":[[{"tag":"text","text":"hi"}]]}}}}`,
+		},
+		{
+			name:     "card",
+			format:   config.FormatCard,
+			template: `{"elements": [{"tag": "note", "elements": [{"tag": "plain_text", "content": {{json .Entry.Title}}}]}]}`,
+			expected: `{"msg_type":"interactive","card":{"elements":[{"tag":"note","elements":[{"tag":"plain_text","content":"Example"}]}]}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var capturedBody []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body json.RawMessage
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Errorf("Failed to decode request body: %v", err)
+				}
+				capturedBody = body
+				w.Write([]byte(`{"code":0,"msg":"success"}`)) //nolint:errcheck
+			}))
+			defer server.Close()
+
+			service := NewFeishuService(testConfig())
+			entry := &models.WebhookEntry{ID: 231, Title: "Example", URL: "https://example.org/article"}
+			feed := &models.WebhookFeed{ID: 8, Title: "Example website"}
+			dest := &config.Destination{Name: "team", WebhookURL: server.URL, Format: tt.format, Template: tt.template}
+
+			if err := service.SendEntryToFeishu(context.Background(), entry, feed, dest); err != nil {
+				t.Fatalf("Failed to send entry to Feishu: %v", err)
+			}
+			if string(capturedBody) != tt.expected {
+				t.Errorf("Expected body %s, got %s", tt.expected, capturedBody)
+			}
+		})
+	}
+}
+
+func TestFeishuService_SendEntryToFeishu_TemplateError(t *testing.T) {
+	service := NewFeishuService(testConfig())
+	entry := &models.WebhookEntry{ID: 231, Title: "Example"}
+	feed := &models.WebhookFeed{ID: 8, Title: "Example website"}
+	// index fails at execution time, which startup validation cannot always catch
+	dest := &config.Destination{Name: "team", WebhookURL: "http://127.0.0.1:1", Template: `{"title": {{json (index .Entry.Tags 3)}}}`}
+
+	err := service.SendEntryToFeishu(context.Background(), entry, feed, dest)
+	if err == nil {
+		t.Fatalf("Expected a render error, got nil")
+	}
+	if isRetryable(err) {
+		t.Errorf("Expected render errors not to be retried")
+	}
+}
+
+func TestTemplateCache_OnlyKeepsConfiguredTemplates(t *testing.T) {
+	configured := `{"title": {{json .Entry.Title}}}`
+	cfg := testConfig()
+	cfg.Destinations = map[string]*config.Destination{"team": {Name: "team", Template: configured}}
+	service := NewFeishuService(cfg)
+
+	entry := &models.WebhookEntry{ID: 231, Title: "Example"}
+	feed := &models.WebhookFeed{ID: 8, Title: "Example website"}
+	previewed := &config.Destination{Name: "preview", Template: `{"title": {{json .Feed.Title}}}`}
+	if _, err := service.RenderMessage(entry, feed, previewed); err != nil {
+		t.Fatalf("Failed to render message: %v", err)
+	}
+
+	if len(service.templates.templates) != 1 || service.templates.templates[configured] == nil {
+		t.Errorf("Expected only the configured template to be cached, got %d", len(service.templates.templates))
+	}
+}
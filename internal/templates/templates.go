@@ -0,0 +1,120 @@
+// Package templates renders user-defined message templates with text/template.
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"text/template"
+	"time"
+
+	"miniflux-feishu/internal/models"
+	"miniflux-feishu/internal/textutil"
+)
+
+// Data is what a template is executed with.
+type Data struct {
+	Entry    *models.WebhookEntry
+	Feed     *models.WebhookFeed
+	Category *models.WebhookCategory
+}
+
+// NewData builds the template data of an entry. Category is never nil so that
+// templates can use .Category.Title without checking for it.
+func NewData(entry *models.WebhookEntry, feed *models.WebhookFeed) Data {
+	category := feed.Category
+	if category == nil {
+		category = &models.WebhookCategory{}
+	}
+	return Data{Entry: entry, Feed: feed, Category: category}
+}
+
+// Funcs are the helpers available to templates, on top of the text/template
+// builtins such as urlquery.
+var Funcs = template.FuncMap{
+	"truncate":   func(length int, s string) string { return textutil.Truncate(s, length) },
+	"stripHTML":  textutil.HTMLToText,
+	"markdown":   func(s string) string { return textutil.HTMLToMarkdown(s, textutil.MarkdownOptions{}) },
+	"formatTime": func(layout string, t time.Time) string { return t.Format(layout) },
+	"json":       toJSON,
+	"humanSize":  textutil.HumanSize,
+}
+
+// toJSON encodes v as JSON, which is how strings are embedded in the JSON
+// templates render.
+func toJSON(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Parse compiles a template.
+func Parse(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(Funcs).Option("missingkey=error").Parse(text)
+}
+
+// Render executes the template for the entry and checks that the result is a
+// JSON object.
+func Render(tmpl *template.Template, entry *models.WebhookEntry, feed *models.WebhookFeed) (json.RawMessage, error) {
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, NewData(entry, feed)); err != nil {
+		return nil, err
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(out.Bytes(), &object); err != nil {
+		return nil, fmt.Errorf("template %s did not render a JSON object: %w", tmpl.Name(), err)
+	}
+	return json.RawMessage(out.Bytes()), nil
+}
+
+// Validate compiles the template and renders it for a sample entry, so that
+// mistakes are reported at startup rather than on the first delivery.
+func Validate(name, text string) error {
+	tmpl, err := Parse(name, text)
+	if err != nil {
+		return err
+	}
+	entry, feed := Sample()
+	_, err = Render(tmpl, entry, feed)
+	return err
+}
+
+// Sample returns an entry with every field set, used to validate and preview
+// templates.
+func Sample() (*models.WebhookEntry, *models.WebhookFeed) {
+	published := time.Date(2023, 8, 17, 19, 29, 22, 0, time.UTC)
+	feed := &models.WebhookFeed{
+		ID:         8,
+		UserID:     1,
+		CategoryID: 2,
+		Category:   &models.WebhookCategory{ID: 2, Title: "Technology"},
+		FeedURL:    "https://example.org/feed.xml",
+		SiteURL:    "https://example.org",
+		Title:      "Example website",
+		CheckedAt:  published,
+	}
+	entry := &models.WebhookEntry{
+		ID:          231,
+		UserID:      1,
+		FeedID:      8,
+		Status:      "unread",
+		Hash:        "1163a93ef12741b558a3b86d7e975c4c1de0152f3439915ed185eb460e5718d7",
+		Title:       "Example",
+		URL:         "https://example.org/article",
+		CommentsURL: "https://example.org/article#comments",
+		Date:        published,
+		CreatedAt:   published,
+		ChangedAt:   published,
+		Content:     "<p>Some <strong>HTML</strong> content</p>",
+		Author:      "Jane Doe",
+		ReadingTime: 1,
+		Enclosures: []models.WebhookEnclosure{
+			{ID: 158, UserID: 1, EntryID: 231, URL: "https://example.org/podcast.mp3", MimeType: "audio/mpeg", Size: 63451045},
+		},
+		Tags: []string{"Some category", "Another label"},
+	}
+	return entry, feed
+}
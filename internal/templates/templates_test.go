@@ -0,0 +1,106 @@
+package templates
+
+import (
+	"testing"
+
+	"miniflux-feishu/internal/models"
+)
+
+func TestRender(t *testing.T) {
+	entry, feed := Sample()
+
+	tests := []struct {
+		name     string
+		title    string
+		template string
+		expected string
+	}{
+		{
+			name:     "fields",
+			template: `{"text": {{json (printf "%s / %s / %s" .Feed.Title .Category.Title .Entry.Author)}}}`,
+			expected: `{"text": "Example website / Technology / Jane Doe"}`,
+		},
+		{
+			name:     "truncate and stripHTML",
+			template: `{"text": {{.Entry.Content | stripHTML | truncate 9 | json}}}`,
+			expected: `{"text": "Some HTML..."}`,
+		},
+		{
+			name:     "formatTime",
+			template: `{"text": {{json (formatTime "2006-01-02" .Entry.Date)}}}`,
+			expected: `{"text": "2023-08-17"}`,
+		},
+		{
+			name:     "urlquery",
+			template: `{"url": "https://example.com/share?title={{urlquery .Entry.Title " & more"}}"}`,
+			expected: `{"url": "https://example.com/share?title=Example+%26+more"}`,
+		},
+		{
+			name:     "json escapes strings",
+			title:    "Say \"hi\"\n",
+			template: `{"text": {{json .Entry.Title}}}`,
+			expected: `{"text": "Say \"hi\"\n"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := Parse(tt.name, tt.template)
+			if err != nil {
+				t.Fatalf("Failed to parse template: %v", err)
+			}
+			e := *entry
+			if tt.title != "" {
+				e.Title = tt.title
+			}
+			result, err := Render(tmpl, &e, feed)
+			if err != nil {
+				t.Fatalf("Failed to render template: %v", err)
+			}
+			if string(result) != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestRender_WithoutCategory(t *testing.T) {
+	tmpl, err := Parse("category", `{"category": {{json .Category.Title}}}`)
+	if err != nil {
+		t.Fatalf("Failed to parse template: %v", err)
+	}
+	result, err := Render(tmpl, &models.WebhookEntry{}, &models.WebhookFeed{})
+	if err != nil {
+		t.Fatalf("Expected feeds without category to render, got %v", err)
+	}
+	if string(result) != `{"category": ""}` {
+		t.Errorf("Expected an empty category, got %s", result)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		valid    bool
+	}{
+		{name: "valid", template: `{"title": {{json .Entry.Title}}}`, valid: true},
+		{name: "syntax error", template: `{"title": {{json .Entry.Title}`},
+		{name: "unknown field", template: `{"title": {{json .Entry.Headline}}}`},
+		{name: "unknown function", template: `{"title": {{shout .Entry.Title}}}`},
+		{name: "not JSON", template: `{{.Entry.Title}}`},
+		{name: "not an object", template: `[{{json .Entry.Title}}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.name, tt.template)
+			if tt.valid && err != nil {
+				t.Errorf("Expected template to be valid, got %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Errorf("Expected an error, got nil")
+			}
+		})
+	}
+}
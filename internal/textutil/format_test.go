@@ -0,0 +1,22 @@
+package textutil
+
+import "testing"
+
+func TestHumanSize(t *testing.T) {
+	tests := []struct {
+		bytes    int64
+		expected string
+	}{
+		{bytes: 0, expected: "0 B"},
+		{bytes: 1023, expected: "1023 B"},
+		{bytes: 1536, expected: "1.5 KB"},
+		{bytes: 63451045, expected: "60.5 MB"},
+		{bytes: 5 << 30, expected: "5.0 GB"},
+	}
+
+	for _, tt := range tests {
+		if result := HumanSize(tt.bytes); result != tt.expected {
+			t.Errorf("Expected HumanSize(%d) = %q, got %q", tt.bytes, tt.expected, result)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+// Package textutil holds the text helpers shared by the message formats and
+// the user templates.
+package textutil
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// TextOptions controls ConvertHTML.
+type TextOptions struct {
+	// LinkFootnotes marks links with [n] and lists their URLs after the text.
+	LinkFootnotes bool
+}
+
+// Text is HTML converted to plain text.
+type Text struct {
+	Body string
+	// Links holds the footnote URLs, Links[i] is referenced as [i+1] in Body.
+	Links []string
+}
+
+// String returns the body followed by the footnotes.
+func (t Text) String() string {
+	return t.Body + t.Footnotes(len(t.Links))
+}
+
+// Footnotes renders the first n footnotes, preceded by a newline.
+func (t Text) Footnotes(n int) string {
+	var b strings.Builder
+	for i, link := range t.Links[:min(n, len(t.Links))] {
+		fmt.Fprintf(&b, "\n[%d] %s", i+1, link)
+	}
+	return b.String()
+}
+
+// HTMLToText converts an HTML fragment to plain text.
+func HTMLToText(content string) string {
+	return ConvertHTML(content, TextOptions{}).Body
+}
+
+// skippedElements are dropped together with their content.
+var skippedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Head: true, atom.Title: true, atom.Iframe: true, atom.Object: true,
+	atom.Svg: true, atom.Math: true, atom.Select: true, atom.Button: true,
+}
+
+// blockElements are put on lines of their own.
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Blockquote: true, atom.Pre: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Section: true, atom.Article: true, atom.Aside: true, atom.Header: true, atom.Footer: true,
+	atom.Nav: true, atom.Main: true, atom.Figure: true, atom.Figcaption: true,
+	atom.Table: true, atom.Tr: true, atom.Ul: true, atom.Ol: true, atom.Li: true,
+	atom.Dl: true, atom.Dt: true, atom.Dd: true, atom.Hr: true, atom.Address: true,
+	atom.Details: true, atom.Summary: true, atom.Form: true, atom.Fieldset: true,
+}
+
+// IsSkippedElement reports whether the element is dropped together with its
+// content, such as a script.
+func IsSkippedElement(a atom.Atom) bool {
+	return skippedElements[a]
+}
+
+// IsBlockElement reports whether the element is put on lines of its own.
+func IsBlockElement(a atom.Atom) bool {
+	return blockElements[a]
+}
+
+// ConvertHTML converts an HTML fragment to plain text: entities are decoded,
+// scripts and other non-content elements are dropped, block elements start
+// new lines and list items get a bullet or their number.
+func ConvertHTML(content string, opts TextOptions) Text {
+	c := &converter{opts: opts}
+	z := html.NewTokenizer(strings.NewReader(content))
+	// ErrorToken is returned at the end of the input; reading from a string
+	// cannot fail otherwise
+	for tt := z.Next(); tt != html.ErrorToken; tt = z.Next() {
+		token := z.Token()
+		switch tt {
+		case html.TextToken:
+			c.text(token.Data)
+		case html.StartTagToken:
+			c.start(token, false)
+		case html.SelfClosingTagToken:
+			c.start(token, true)
+		case html.EndTagToken:
+			c.end(token)
+		}
+	}
+	return Text{Body: strings.TrimSpace(string(c.out)), Links: c.links}
+}
+
+type converter struct {
+	opts TextOptions
+	out  []byte
+
+	// skip is the element being dropped and skipDepth its nesting level
+	skip      atom.Atom
+	skipDepth int
+
+	pre     int
+	lists   []*list
+	anchors []anchor
+	links   []string
+	// newline is set when the next text has to start on a new line
+	newline bool
+}
+
+type list struct {
+	ordered bool
+	index   int
+}
+
+type anchor struct {
+	href  string
+	start int
+}
+
+func (c *converter) start(token html.Token, selfClosing bool) {
+	if c.skipDepth > 0 {
+		if token.DataAtom == c.skip && !selfClosing {
+			c.skipDepth++
+		}
+		return
+	}
+	if skippedElements[token.DataAtom] {
+		if !selfClosing {
+			c.skip, c.skipDepth = token.DataAtom, 1
+		}
+		return
+	}
+
+	switch token.DataAtom {
+	case atom.Br:
+		c.breakLine()
+		return
+	case atom.Td, atom.Th:
+		c.write(" ")
+		return
+	}
+
+	if blockElements[token.DataAtom] {
+		c.breakLine()
+	}
+
+	switch token.DataAtom {
+	case atom.Pre:
+		c.pre++
+	case atom.Ul, atom.Ol:
+		if !selfClosing {
+			c.lists = append(c.lists, &list{ordered: token.DataAtom == atom.Ol})
+		}
+	case atom.Li:
+		c.listMarker()
+	case atom.A:
+		if !selfClosing {
+			c.anchors = append(c.anchors, anchor{href: linkURL(token), start: len(c.out)})
+		}
+	}
+}
+
+func (c *converter) end(token html.Token) {
+	if c.skipDepth > 0 {
+		if token.DataAtom == c.skip {
+			c.skipDepth--
+		}
+		return
+	}
+
+	switch token.DataAtom {
+	case atom.Pre:
+		if c.pre > 0 {
+			c.pre--
+		}
+	case atom.Ul, atom.Ol:
+		if len(c.lists) > 0 {
+			c.lists = c.lists[:len(c.lists)-1]
+		}
+	case atom.A:
+		if len(c.anchors) > 0 {
+			a := c.anchors[len(c.anchors)-1]
+			c.anchors = c.anchors[:len(c.anchors)-1]
+			c.footnote(a)
+		}
+	}
+
+	if blockElements[token.DataAtom] {
+		c.breakLine()
+	}
+}
+
+func (c *converter) text(data string) {
+	if c.skipDepth > 0 {
+		return
+	}
+	if c.pre > 0 {
+		for i, line := range strings.Split(data, "\n") {
+			if i > 0 {
+				c.hardBreak()
+			}
+			c.writeRaw(line)
+		}
+		return
+	}
+	c.write(CollapseSpace(data))
+}
+
+// footnote adds a reference to the link that just ended, unless its text
+// already is the URL.
+func (c *converter) footnote(a anchor) {
+	if !c.opts.LinkFootnotes || a.href == "" {
+		return
+	}
+	text := strings.TrimSpace(string(c.out[a.start:]))
+	if text == "" || text == a.href || strings.TrimSuffix(a.href, "/") == text {
+		return
+	}
+	c.links = append(c.links, a.href)
+	c.write(" [" + strconv.Itoa(len(c.links)) + "]")
+}
+
+// listMarker starts a list item with a bullet or its number, indented by the
+// nesting depth.
+func (c *converter) listMarker() {
+	if len(c.lists) == 0 {
+		c.write("• ")
+		return
+	}
+	l := c.lists[len(c.lists)-1]
+	l.index++
+	c.writeRaw(ListMarker(l.ordered, l.index, len(c.lists)))
+}
+
+// ListMarker returns the bullet, or the number for ordered lists, that starts
+// the index-th item of a list nested depth levels deep, indented accordingly.
+func ListMarker(ordered bool, index, depth int) string {
+	marker := "• "
+	if ordered {
+		marker = strconv.Itoa(index) + ". "
+	}
+	return strings.Repeat("  ", max(depth-1, 0)) + marker
+}
+
+// write adds collapsed text, dropping spaces at the start of a line.
+func (c *converter) write(s string) {
+	n := len(c.out)
+	if n == 0 || c.newline || c.out[n-1] == '\n' {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+	} else if strings.HasPrefix(s, " ") && c.out[n-1] == ' ' {
+		s = s[1:]
+	}
+	c.writeRaw(s)
+}
+
+func (c *converter) writeRaw(s string) {
+	if s == "" {
+		return
+	}
+	if c.newline {
+		c.out = bytes.TrimRight(c.out, " \t")
+		if len(c.out) > 0 {
+			c.out = append(c.out, '\n')
+		}
+		c.newline = false
+	}
+	c.out = append(c.out, s...)
+}
+
+// breakLine ends the current line. Consecutive breaks collapse into one, so
+// nested blocks do not produce blank lines.
+func (c *converter) breakLine() {
+	c.newline = true
+}
+
+// hardBreak ends the current line even if it is empty, for <pre>.
+func (c *converter) hardBreak() {
+	if c.newline && len(c.out) > 0 {
+		c.out = append(c.out, '\n')
+	}
+	c.newline = true
+}
+
+func linkURL(token html.Token) string {
+	return LinkURL(Attr(token.Attr, "href"))
+}
+
+// Attr returns the value of the attribute key, or "" if it is not set.
+func Attr(attrs []html.Attribute, key string) string {
+	for _, a := range attrs {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// LinkURL returns link if it is an absolute http or https URL, or uses one of
+// the extra schemes such as "mailto", and "" otherwise. Feed content can hold
+// javascript: and relative links that Feishu cannot open.
+func LinkURL(link string, extraSchemes ...string) string {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return ""
+	}
+	switch {
+	case u.Scheme == "http" || u.Scheme == "https":
+		if u.Host == "" {
+			return ""
+		}
+	case u.Scheme == "" || !slices.Contains(extraSchemes, u.Scheme):
+		return ""
+	}
+	return u.String()
+}
+
+// CollapseSpace replaces runs of whitespace with a single space, like a
+// browser does outside of <pre>.
+func CollapseSpace(s string) string {
+	var out strings.Builder
+	space := false
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if space {
+			out.WriteByte(' ')
+			space = false
+		}
+		out.WriteRune(r)
+	}
+	if space {
+		out.WriteByte(' ')
+	}
+	return out.String()
+}
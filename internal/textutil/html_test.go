@@ -0,0 +1,194 @@
+package textutil
+
+import "testing"
+
+func TestConvertHTML(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		footnotes bool
+		expected  string
+	}{
+		{
+			name:     "plain text",
+			input:    "Just text",
+			expected: "Just text",
+		},
+		{
+			name:     "entities",
+			input:    "<p>Tom &amp; Jerry&nbsp;&mdash; &lt;cartoon&gt; &#39;1940&#39; &copy;</p>",
+			expected: "Tom & Jerry — <cartoon> '1940' ©",
+		},
+		{
+			name:     "script and style are dropped",
+			input:    `<style>.x { color: red }</style><p>Visible</p><script>var a = "<p>hidden</p>";</script><noscript>Enable JS</noscript>`,
+			expected: "Visible",
+		},
+		{
+			name:     "greater-than sign inside attribute",
+			input:    `<p><a title="a > b" href="https://example.org">link</a> and <img alt="x > y" src="a.png"> text</p>`,
+			expected: "link and text",
+		},
+		{
+			name:     "blank lines collapse",
+			input:    "<p>One</p>\n\n\n<p></p><div><div><p>Two</p></div></div><br><br><br>Three",
+			expected: "One\nTwo\nThree",
+		},
+		{
+			name:     "whitespace inside paragraphs",
+			input:    "<p>\n  Lorem    ipsum\n  dolor <em>sit</em>\tamet\n</p>",
+			expected: "Lorem ipsum dolor sit amet",
+		},
+		{
+			name:     "unordered list",
+			input:    "<p>Changes:</p><ul>\n<li>Fixed a crash</li>\n<li>Faster <code>sync</code></li>\n</ul>",
+			expected: "Changes:\n• Fixed a crash\n• Faster sync",
+		},
+		{
+			name:     "nested ordered list",
+			input:    "<ol><li>Install<ul><li>Linux</li><li>macOS</li></ul></li><li>Configure</li></ol>",
+			expected: "1. Install\n  • Linux\n  • macOS\n2. Configure",
+		},
+		{
+			name:     "preformatted code",
+			input:    "<p>Run:</p><pre><code>go build ./...\n\ngo test ./...</code></pre><p>Done</p>",
+			expected: "Run:\ngo build ./...\n\ngo test ./...\nDone",
+		},
+		{
+			name:     "comments and doctype",
+			input:    "<!DOCTYPE html><!-- generated --><p>Body<!-- inline --> text</p>",
+			expected: "Body text",
+		},
+		{
+			name:     "table cells",
+			input:    "<table><tr><th>Name</th><th>Score</th></tr><tr><td>Alice</td><td>10</td></tr></table>",
+			expected: "Name Score\nAlice 10",
+		},
+		{
+			name:      "link footnotes",
+			input:     `<p>See <a href="https://example.org/docs">the docs</a> and <a href="https://example.org/">https://example.org/</a> or <a href="javascript:void(0)">this</a>.</p><p>Also <a href="https://example.org/faq">FAQ</a>.</p>`,
+			footnotes: true,
+			expected:  "See the docs [1] and https://example.org/ or this.\nAlso FAQ [2].\n[1] https://example.org/docs\n[2] https://example.org/faq",
+		},
+		{
+			name:     "links without footnotes",
+			input:    `<p>See <a href="https://example.org/docs">the docs</a>.</p>`,
+			expected: "See the docs.",
+		},
+		{
+			name: "wordpress post",
+			input: `<div class="entry-content">
+<figure class="wp-block-image size-large"><img decoding="async" src="https://blog.example.com/wp-content/uploads/2023/08/cover.jpg" alt="" class="wp-image-42"/><figcaption>Photo by Someone</figcaption></figure>
+<p>We&#8217;re excited to announce version 2.0!</p>
+<h2 class="wp-block-heading">What&#8217;s new</h2>
+<ul>
+<li>Dark mode</li>
+<li>Offline support</li>
+</ul>
+<p>The post <a rel="nofollow" href="https://blog.example.com/2-0/">Version 2.0</a> appeared first on <a rel="nofollow" href="https://blog.example.com">Example Blog</a>.</p>
+</div>`,
+			expected: "Photo by Someone\nWe’re excited to announce version 2.0!\nWhat’s new\n• Dark mode\n• Offline support\nThe post Version 2.0 appeared first on Example Blog.",
+		},
+		{
+			name: "youtube embed",
+			input: `<p>Watch the talk:</p>
+<iframe width="560" height="315" src="https://www.youtube.com/embed/abc" frameborder="0" allowfullscreen>Your browser does not support iframes</iframe>
+<p>Slides are <a href="https://example.org/slides.pdf">here</a>.</p>`,
+			expected: "Watch the talk:\nSlides are here.",
+		},
+		{
+			name:     "hacker news comments link",
+			input:    `<p>Article URL: <a href="https://example.org/post">https://example.org/post</a></p><p>Comments URL: <a href="https://news.ycombinator.com/item?id=1">https://news.ycombinator.com/item?id=1</a></p><p>Points: 120</p><p># Comments: 45</p>`,
+			expected: "Article URL: https://example.org/post\nComments URL: https://news.ycombinator.com/item?id=1\nPoints: 120\n# Comments: 45",
+		},
+		{
+			name:     "chinese blog",
+			input:    `<p>&nbsp;&nbsp;&nbsp;&nbsp;今天我们来聊聊&ldquo;并发&rdquo;。</p><blockquote><p>不要通过共享内存来通信，而要通过通信来共享内存。</p></blockquote><p><strong>总结：</strong>多用 channel。</p>`,
+			expected: "今天我们来聊聊“并发”。\n不要通过共享内存来通信，而要通过通信来共享内存。\n总结：多用 channel。",
+		},
+		{
+			name:     "inline svg",
+			input:    `<p><svg viewBox="0 0 10 10"><title>icon</title><path d="M0 0"/><text>SVG text</text></svg>Label</p>`,
+			expected: "Label",
+		},
+		{
+			name:     "unclosed tags",
+			input:    "<p>First<p>Second <b>bold<li>item",
+			expected: "First\nSecond bold\n• item",
+		},
+		{
+			name:     "empty",
+			input:    "",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ConvertHTML(tt.input, TextOptions{LinkFootnotes: tt.footnotes}).String()
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "simple paragraph",
+			input:    "<p>Some HTML content</p>",
+			expected: "Some HTML content",
+		},
+		{
+			name:     "multiple tags",
+			input:    "<p>This is <strong>bold</strong> and <em>italic</em> text</p>",
+			expected: "This is bold and italic text",
+		},
+		{
+			name:     "line breaks",
+			input:    "Line 1<br>Line 2<br/>Line 3<br />Line 4",
+			expected: "Line 1\nLine 2\nLine 3\nLine 4",
+		},
+		{
+			name:     "entities and scripts",
+			input:    "<p>Tom &amp; Jerry</p><script>alert('<b>')</script>",
+			expected: "Tom & Jerry",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := HTMLToText(tt.input)
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestLinkURL(t *testing.T) {
+	tests := []struct {
+		link     string
+		extra    []string
+		expected string
+	}{
+		{link: " https://example.org/a?b=c ", expected: "https://example.org/a?b=c"},
+		{link: "http://example.org", expected: "http://example.org"},
+		{link: "javascript:alert(1)", expected: ""},
+		{link: "/relative/path", expected: ""},
+		{link: "https:///no-host", expected: ""},
+		{link: "mailto:jane@example.org", expected: ""},
+		{link: "mailto:jane@example.org", extra: []string{"mailto"}, expected: "mailto:jane@example.org"},
+	}
+
+	for _, tt := range tests {
+		if result := LinkURL(tt.link, tt.extra...); result != tt.expected {
+			t.Errorf("LinkURL(%q, %v): expected %q, got %q", tt.link, tt.extra, tt.expected, result)
+		}
+	}
+}
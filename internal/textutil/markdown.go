@@ -0,0 +1,474 @@
+package textutil
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// MaxMarkdownLength is the default output limit of HTMLToMarkdown. It keeps a
+// card markdown element, together with the rest of the card, well under the
+// 30 KB Feishu accepts for a message.
+const MaxMarkdownLength = 5000
+
+// MarkdownOptions controls HTMLToMarkdown.
+type MarkdownOptions struct {
+	// MaxText limits the visible text, not counting markup. Zero means no
+	// limit.
+	MaxText int
+	// MaxLength limits the output in characters, markup included.
+	// MaxMarkdownLength when zero.
+	MaxLength int
+	// Ellipsis is appended when content was cut, DefaultEllipsis when empty.
+	Ellipsis string
+	// DisplayWidth counts MaxText in display columns, wide characters
+	// counting double.
+	DisplayWidth bool
+}
+
+// markdownEscapes replaces the characters lark_md gives a meaning to with
+// character references, which Feishu renders as the plain character.
+var markdownEscapes = map[rune]string{
+	'*': "&#42;", '_': "&#95;", '~': "&#126;", '`': "&#96;",
+	'[': "&#91;", ']': "&#93;", '<': "&lt;", '>': "&gt;", '&': "&amp;",
+}
+
+// lineStartEscapes are only special at the start of a line, where they would
+// begin a heading, quote or list.
+var lineStartEscapes = map[rune]string{
+	'#': "&#35;", '-': "&#45;", '+': "&#43;",
+}
+
+// HTMLToMarkdown converts an HTML fragment to the Markdown subset supported by
+// card markdown elements: links, bold, italics, strikethrough, inline code,
+// code blocks and lists. Other elements keep their text only, and text that
+// would be read as Markdown is escaped.
+func HTMLToMarkdown(content string, opts MarkdownOptions) string {
+	if opts.MaxLength <= 0 {
+		opts.MaxLength = MaxMarkdownLength
+	}
+	if opts.Ellipsis == "" {
+		opts.Ellipsis = DefaultEllipsis
+	}
+	c := &mdConverter{opts: opts, lineStart: true}
+	z := html.NewTokenizer(strings.NewReader(content))
+	for tt := z.Next(); tt != html.ErrorToken && !c.done; tt = z.Next() {
+		token := z.Token()
+		switch tt {
+		case html.TextToken:
+			c.text(token.Data)
+		case html.StartTagToken:
+			c.start(token, false)
+		case html.SelfClosingTagToken:
+			c.start(token, true)
+		case html.EndTagToken:
+			c.end(token)
+		}
+	}
+	if !c.done {
+		c.closeSpans(0)
+	}
+	return strings.TrimSpace(string(c.out))
+}
+
+type mdConverter struct {
+	opts MarkdownOptions
+	out  []byte
+	// length counts the runes in out, visible only the text among them
+	length  int
+	visible int
+
+	skip      atom.Atom
+	skipDepth int
+
+	pre   int
+	lists []*list
+	spans []*span
+	// newline is set when the next text has to start on a new line
+	newline bool
+	// lineStart is set until text is written on the current line, lineDigits
+	// while that text only has digits
+	lineStart  bool
+	lineDigits bool
+	done       bool
+}
+
+// span is an inline element with Markdown delimiters. They are only written
+// once the element has text, so empty elements leave no stray markup.
+type span struct {
+	tag    atom.Atom
+	open   string
+	close  string
+	opened bool
+}
+
+// markdownStyles are the inline elements lark_md has a syntax for.
+var markdownStyles = map[atom.Atom]string{
+	atom.B: "**", atom.Strong: "**",
+	atom.I: "*", atom.Em: "*",
+	atom.S: "~~", atom.Strike: "~~", atom.Del: "~~",
+	atom.Code: "`",
+	atom.H1:   "**", atom.H2: "**", atom.H3: "**", atom.H4: "**", atom.H5: "**", atom.H6: "**",
+}
+
+func (c *mdConverter) start(token html.Token, selfClosing bool) {
+	if c.skipDepth > 0 {
+		if token.DataAtom == c.skip && !selfClosing {
+			c.skipDepth++
+		}
+		return
+	}
+	if skippedElements[token.DataAtom] {
+		if !selfClosing {
+			c.skip, c.skipDepth = token.DataAtom, 1
+		}
+		return
+	}
+
+	switch token.DataAtom {
+	case atom.Br:
+		c.breakLine()
+		return
+	case atom.Td, atom.Th:
+		c.pendingSpace()
+		return
+	}
+
+	if blockElements[token.DataAtom] {
+		c.breakLine()
+	}
+	if selfClosing {
+		return
+	}
+
+	switch token.DataAtom {
+	case atom.Pre:
+		if c.pre == 0 {
+			c.spans = append(c.spans, &span{tag: atom.Pre, open: "```\n", close: "\n```"})
+		}
+		c.pre++
+	case atom.Ul, atom.Ol:
+		c.lists = append(c.lists, &list{ordered: token.DataAtom == atom.Ol})
+	case atom.Li:
+		c.listMarker()
+	case atom.A:
+		s := &span{tag: atom.A}
+		if href := linkURL(token); href != "" && c.pre == 0 {
+			s.open = "["
+			s.close = "](" + markdownURL.Replace(href) + ")"
+		}
+		c.spans = append(c.spans, s)
+	default:
+		if delim, ok := markdownStyles[token.DataAtom]; ok && c.pre == 0 {
+			c.spans = append(c.spans, &span{tag: token.DataAtom, open: delim, close: delim})
+		}
+	}
+}
+
+func (c *mdConverter) end(token html.Token) {
+	if c.skipDepth > 0 {
+		if token.DataAtom == c.skip {
+			c.skipDepth--
+		}
+		return
+	}
+
+	switch token.DataAtom {
+	case atom.Pre:
+		if c.pre > 0 {
+			c.pre--
+		}
+		if c.pre == 0 {
+			c.closeSpan(atom.Pre)
+		}
+	case atom.Ul, atom.Ol:
+		if len(c.lists) > 0 {
+			c.lists = c.lists[:len(c.lists)-1]
+		}
+	default:
+		c.closeSpan(token.DataAtom)
+	}
+
+	if blockElements[token.DataAtom] {
+		c.breakLine()
+	}
+}
+
+func (c *mdConverter) text(data string) {
+	if c.skipDepth > 0 {
+		return
+	}
+	if c.pre > 0 {
+		for i, line := range strings.Split(data, "\n") {
+			if i > 0 && !c.writeMarkup("\n") {
+				return
+			}
+			c.writeText(line, false)
+		}
+		return
+	}
+
+	s := CollapseSpace(data)
+	if c.newline || c.lineStart || c.endsWithSpace() {
+		s = strings.TrimLeft(s, " ")
+	}
+	if s == "" {
+		return
+	}
+	// Delimiters have to touch the text they wrap
+	if strings.HasPrefix(s, " ") {
+		c.writeMarkup(" ")
+		s = s[1:]
+	}
+	// Code spans show their content as is, so it cannot be escaped; a
+	// backtick would end the span early
+	if c.inSpan(atom.Code) {
+		c.writeText(strings.ReplaceAll(s, "`", "'"), false)
+		return
+	}
+	c.writeText(s, true)
+}
+
+func (c *mdConverter) inSpan(tag atom.Atom) bool {
+	for _, s := range c.spans {
+		if s.tag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// listMarker starts a list item with a dash or its number, indented by the
+// nesting depth.
+func (c *mdConverter) listMarker() {
+	depth := max(len(c.lists), 1)
+	marker := "- "
+	if len(c.lists) > 0 {
+		l := c.lists[len(c.lists)-1]
+		l.index++
+		if l.ordered {
+			marker = strconv.Itoa(l.index) + ". "
+		}
+	}
+	if c.flushLine() {
+		c.writeMarkup(strings.Repeat("    ", depth-1) + marker)
+	}
+}
+
+// writeText adds visible text, opening the pending spans first. It steps over
+// grapheme clusters so that accents and emoji sequences stay whole. Text that
+// does not fit the limits ends the output, at the last word boundary when that
+// keeps at least half of the text.
+func (c *mdConverter) writeText(s string, escape bool) {
+	if s == "" || !c.flushLine() || !c.openSpans() {
+		return
+	}
+	var (
+		state = -1
+		rest  = s
+		// word is the output state after the last word boundary
+		word struct{ out, length, visible int }
+	)
+	for len(rest) > 0 {
+		cluster, next, boundaries, newState := uniseg.StepString(rest, state)
+		rest, state = next, newState
+
+		out := cluster
+		if escape {
+			var b strings.Builder
+			for _, r := range cluster {
+				b.WriteString(c.escape(r))
+			}
+			out = b.String()
+		}
+		size := 1
+		if c.opts.DisplayWidth {
+			size = boundaries >> uniseg.ShiftWidth
+		}
+		if !c.fits(utf8.RuneCountInString(out), size) {
+			if word.out > 0 && word.visible*2 >= c.visible {
+				c.out, c.length, c.visible = c.out[:word.out], word.length, word.visible
+			}
+			c.truncate()
+			return
+		}
+		c.out = append(c.out, out...)
+		c.length += utf8.RuneCountInString(out)
+		c.visible += size
+		c.lineDigits = (c.lineStart || c.lineDigits) && len(cluster) == 1 && cluster[0] >= '0' && cluster[0] <= '9'
+		c.lineStart = false
+
+		if boundaries&uniseg.MaskWord != 0 {
+			word.out, word.length, word.visible = len(c.out), c.length, c.visible
+		}
+	}
+}
+
+// markdownURL escapes the parentheses that would end a link target early.
+var markdownURL = strings.NewReplacer("(", "%28", ")", "%29")
+
+// MarkdownLink returns a lark_md link showing text.
+func MarkdownLink(text, href string) string {
+	return "[" + EscapeMarkdown(text) + "](" + markdownURL.Replace(href) + ")"
+}
+
+// EscapeMarkdown escapes plain text, such as a feed title, so that lark_md
+// shows it as is.
+func EscapeMarkdown(s string) string {
+	c := &mdConverter{lineStart: true}
+	var b strings.Builder
+	for _, r := range s {
+		if r == '\n' {
+			b.WriteRune(r)
+			c.lineStart, c.lineDigits = true, false
+			continue
+		}
+		b.WriteString(c.escape(r))
+		c.lineDigits = (c.lineStart || c.lineDigits) && r >= '0' && r <= '9'
+		c.lineStart = false
+	}
+	return b.String()
+}
+
+func (c *mdConverter) escape(r rune) string {
+	if e, ok := markdownEscapes[r]; ok {
+		return e
+	}
+	if e, ok := lineStartEscapes[r]; ok && c.lineStart {
+		return e
+	}
+	// "1." at the start of a line would become an ordered list
+	if r == '.' && c.lineDigits {
+		return "&#46;"
+	}
+	return string(r)
+}
+
+// writeMarkup adds text that is not counted as visible, reporting false when
+// it does not fit.
+func (c *mdConverter) writeMarkup(s string) bool {
+	n := utf8.RuneCountInString(s)
+	if !c.fits(n, 0) {
+		c.truncate()
+		return false
+	}
+	c.out = append(c.out, s...)
+	c.length += n
+	return true
+}
+
+// flushLine starts the pending new line.
+func (c *mdConverter) flushLine() bool {
+	if !c.newline {
+		return true
+	}
+	c.newline = false
+	c.trimSpace()
+	if len(c.out) == 0 {
+		return true
+	}
+	if !c.writeMarkup("\n") {
+		return false
+	}
+	c.lineStart, c.lineDigits = true, false
+	return true
+}
+
+func (c *mdConverter) openSpans() bool {
+	for _, s := range c.spans {
+		if s.opened || s.open == "" {
+			continue
+		}
+		// Reserve the closing delimiter together with the opening one
+		if !c.fits(utf8.RuneCountInString(s.open+s.close), 0) {
+			c.truncate()
+			return false
+		}
+		c.out = append(c.out, s.open...)
+		c.length += utf8.RuneCountInString(s.open)
+		s.opened = true
+	}
+	return true
+}
+
+// closeSpan closes the innermost span of the tag, and the spans opened inside
+// it that were left open by malformed HTML.
+func (c *mdConverter) closeSpan(tag atom.Atom) {
+	for i := len(c.spans) - 1; i >= 0; i-- {
+		if c.spans[i].tag == tag {
+			c.closeSpans(i)
+			return
+		}
+	}
+}
+
+// closeSpans writes the closing delimiters of the spans from index i on. Their
+// room was reserved when they were opened.
+func (c *mdConverter) closeSpans(i int) {
+	for j := len(c.spans) - 1; j >= i; j-- {
+		s := c.spans[j]
+		if !s.opened {
+			continue
+		}
+		trailing := c.trimSpace()
+		c.out = append(c.out, s.close...)
+		c.length += utf8.RuneCountInString(s.close)
+		if trailing {
+			c.out = append(c.out, ' ')
+			c.length++
+		}
+	}
+	c.spans = c.spans[:i]
+}
+
+// fits reports whether markup runes and visible characters can be added while
+// leaving room for the open spans to be closed and for the ellipsis.
+func (c *mdConverter) fits(markup, visible int) bool {
+	if c.opts.MaxText > 0 && c.visible+visible > c.opts.MaxText {
+		return false
+	}
+	reserved := utf8.RuneCountInString(c.opts.Ellipsis)
+	for _, s := range c.spans {
+		if s.opened {
+			reserved += utf8.RuneCountInString(s.close)
+		}
+	}
+	return c.length+markup+reserved <= c.opts.MaxLength
+}
+
+// truncate closes everything that is open and ends the output with the
+// ellipsis.
+func (c *mdConverter) truncate() {
+	c.done = true
+	c.closeSpans(0)
+	c.trimSpace()
+	c.out = append(c.out, c.opts.Ellipsis...)
+}
+
+func (c *mdConverter) pendingSpace() {
+	if !c.newline && !c.lineStart && !c.endsWithSpace() {
+		c.writeMarkup(" ")
+	}
+}
+
+func (c *mdConverter) breakLine() {
+	c.newline = true
+}
+
+func (c *mdConverter) endsWithSpace() bool {
+	return len(c.out) > 0 && c.out[len(c.out)-1] == ' '
+}
+
+// trimSpace drops trailing spaces and reports whether there were any.
+func (c *mdConverter) trimSpace() bool {
+	n := len(c.out)
+	for len(c.out) > 0 && c.out[len(c.out)-1] == ' ' {
+		c.out = c.out[:len(c.out)-1]
+	}
+	c.length -= n - len(c.out)
+	return n != len(c.out)
+}
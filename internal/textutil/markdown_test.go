@@ -0,0 +1,169 @@
+package textutil
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestHTMLToMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "inline styles",
+			input:    "<p>Plain <b>bold</b>, <em>italic </em>and <del>gone</del></p>",
+			expected: "Plain **bold**, *italic* and ~~gone~~",
+		},
+		{
+			name:     "links",
+			input:    `<p>See <a href="https://example.org/a_(b)">the <strong>docs</strong></a> or <a href="javascript:alert(1)">this</a>.</p>`,
+			expected: "See [the **docs**](https://example.org/a_%28b%29) or this.",
+		},
+		{
+			name:     "empty elements leave no markup",
+			input:    "<p><b></b><i> </i>text<a href=\"https://example.org\"></a></p>",
+			expected: "text",
+		},
+		{
+			name:     "special characters are escaped",
+			input:    "<p>2*3 = 6, snake_case, ~home, [1] &lt;tag&gt; &amp; `tick`</p>",
+			expected: "2&#42;3 = 6, snake&#95;case, &#126;home, &#91;1&#93; &lt;tag&gt; &amp; &#96;tick&#96;",
+		},
+		{
+			name:     "line starts are escaped",
+			input:    "<p># not a heading</p><p>- not a list</p><p>1984. A year</p><p>Mid-line # and 1. stay</p>",
+			expected: "&#35; not a heading\n&#45; not a list\n1984&#46; A year\nMid-line # and 1. stay",
+		},
+		{
+			name:     "inline code is not escaped",
+			input:    "<p>Run <code>a*b_c</code> now</p>",
+			expected: "Run `a*b_c` now",
+		},
+		{
+			name:     "code block",
+			input:    "<p>Example:</p><pre><code>if a < b {\n    return *p\n}</code></pre><p>Done</p>",
+			expected: "Example:\n```\nif a < b {\n    return *p\n}\n```\nDone",
+		},
+		{
+			name:     "lists",
+			input:    "<ul><li>One</li><li>Two<ol><li>First</li><li>Second</li></ol></li></ul>",
+			expected: "- One\n- Two\n    1. First\n    2. Second",
+		},
+		{
+			name:     "headings are bold",
+			input:    "<h2>What's new</h2><p>Dark mode</p>",
+			expected: "**What's new**\nDark mode",
+		},
+		{
+			name:     "scripts and images are dropped",
+			input:    `<script>alert("*")</script><p><img src="a.png" alt="A">Caption</p>`,
+			expected: "Caption",
+		},
+		{
+			name:     "unclosed tags",
+			input:    "<p>Start <b>bold <i>both",
+			expected: "Start **bold *both***",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := HTMLToMarkdown(tt.input, MarkdownOptions{}); result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestHTMLToMarkdown_Limits(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		opts     MarkdownOptions
+		expected string
+	}{
+		{
+			name:     "visible text limit closes styles",
+			input:    "<p>Hello <b>bold words</b> more</p>",
+			opts:     MarkdownOptions{MaxText: 9},
+			expected: "Hello **bol**...",
+		},
+		{
+			name:     "markup does not count as text",
+			input:    `<p><a href="https://example.org/long/path">link</a> text</p>`,
+			opts:     MarkdownOptions{MaxText: 4},
+			expected: "[link](https://example.org/long/path)...",
+		},
+		{
+			name:     "output limit leaves room for closing markup",
+			input:    `<p>Hello <a href="https://example.org/long/path">link</a></p>`,
+			opts:     MarkdownOptions{MaxLength: 20},
+			expected: "Hello...",
+		},
+		{
+			name:     "display width",
+			input:    "<p>你好<b>世界</b></p>",
+			opts:     MarkdownOptions{MaxText: 6, DisplayWidth: true, Ellipsis: "…"},
+			expected: "你好**世**…",
+		},
+		{
+			name:     "cuts at a word boundary",
+			input:    "<p>Hello wonderful world</p>",
+			opts:     MarkdownOptions{MaxText: 10},
+			expected: "Hello...",
+		},
+		{
+			name:     "keeps grapheme clusters whole",
+			input:    "<p>ab\U0001F468\u200D\U0001F469\u200D\U0001F467cafe\u0301</p>",
+			opts:     MarkdownOptions{MaxText: 3},
+			expected: "ab\U0001F468\u200D\U0001F469\u200D\U0001F467...",
+		},
+		{
+			name:     "decomposed accent counts once",
+			input:    "<p>cafe\u0301s</p>",
+			opts:     MarkdownOptions{MaxText: 4},
+			expected: "cafe\u0301...",
+		},
+		{
+			name:     "code block is closed",
+			input:    "<pre>line one\nline two</pre>",
+			opts:     MarkdownOptions{MaxText: 10},
+			expected: "```\nline one\nli\n```...",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := HTMLToMarkdown(tt.input, tt.opts); result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+
+	long := "<p>" + strings.Repeat("<b>word</b> * ", 2000) + "</p>"
+	if result := HTMLToMarkdown(long, MarkdownOptions{}); utf8.RuneCountInString(result) > MaxMarkdownLength {
+		t.Errorf("Expected at most %d characters, got %d", MaxMarkdownLength, utf8.RuneCountInString(result))
+	}
+}
+
+func TestEscapeMarkdown(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Example website", "Example website"},
+		{"**Big** [news](https://example.org)", "&#42;&#42;Big&#42;&#42; &#91;news&#93;(https://example.org)"},
+		{"# Weekly - notes", "&#35; Weekly - notes"},
+		{"1. first", "1&#46; first"},
+		{"a <at id=all></at>", "a &lt;at id=all&gt;&lt;/at&gt;"},
+	}
+
+	for _, tt := range tests {
+		if result := EscapeMarkdown(tt.input); result != tt.expected {
+			t.Errorf("EscapeMarkdown(%q): expected %q, got %q", tt.input, tt.expected, result)
+		}
+	}
+}
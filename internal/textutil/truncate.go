@@ -0,0 +1,88 @@
+package textutil
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/rivo/uniseg"
+)
+
+// DefaultEllipsis is appended to truncated text unless another one is set.
+const DefaultEllipsis = "..."
+
+// TruncateOptions controls TruncateText.
+type TruncateOptions struct {
+	// Length is the maximum length of the kept text, not counting the
+	// ellipsis. Zero disables truncation.
+	Length int
+	// Ellipsis is appended when text was cut, DefaultEllipsis when empty.
+	Ellipsis string
+	// DisplayWidth measures the length in terminal columns, so that wide
+	// characters such as CJK count double, instead of in characters.
+	DisplayWidth bool
+}
+
+// Truncate shortens s to at most length characters, ending it with "..." when
+// something was cut.
+func Truncate(s string, length int) string {
+	return TruncateText(s, TruncateOptions{Length: length})
+}
+
+// TruncateText shortens s without splitting grapheme clusters, so accents and
+// emoji sequences stay whole. It cuts at the last sentence or, failing that,
+// word boundary when that keeps at least half of the allowed length.
+func TruncateText(s string, opts TruncateOptions) string {
+	if opts.Length <= 0 {
+		return s
+	}
+
+	var (
+		state                   = -1
+		rest                    = s
+		pos, used               int
+		wordPos, wordUsed       int
+		sentencePos, sentenceAt int
+	)
+	for len(rest) > 0 {
+		cluster, next, boundaries, newState := uniseg.StepString(rest, state)
+		size := 1
+		if opts.DisplayWidth {
+			size = boundaries >> uniseg.ShiftWidth
+		}
+		if used+size > opts.Length {
+			break
+		}
+		used += size
+		pos += len(cluster)
+		rest, state = next, newState
+
+		if boundaries&uniseg.MaskWord != 0 {
+			wordPos, wordUsed = pos, used
+		}
+		if boundaries&uniseg.MaskSentence != 0 {
+			sentencePos, sentenceAt = pos, used
+		}
+	}
+	if rest == "" {
+		return s
+	}
+
+	cut := pos
+	switch {
+	case sentenceAt*2 >= opts.Length:
+		cut = sentencePos
+	case wordUsed*2 >= opts.Length:
+		cut = wordPos
+	}
+
+	ellipsis := opts.Ellipsis
+	if ellipsis == "" {
+		ellipsis = DefaultEllipsis
+	}
+	return strings.TrimRightFunc(s[:cut], unicode.IsSpace) + ellipsis
+}
+
+// Width returns the display width of s, with wide characters counting double.
+func Width(s string) int {
+	return uniseg.StringWidth(s)
+}
@@ -0,0 +1,54 @@
+package textutil
+
+import "testing"
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		length   int
+		expected string
+	}{
+		{name: "short", input: "hello", length: 10, expected: "hello"},
+		{name: "exact", input: "hello", length: 5, expected: "hello"},
+		{name: "cut", input: "hello world", length: 5, expected: "hello..."},
+		{name: "multibyte", input: "你好世界", length: 2, expected: "你好..."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := Truncate(tt.input, tt.length); result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		opts     TruncateOptions
+		expected string
+	}{
+		{name: "no limit", input: "hello world", opts: TruncateOptions{}, expected: "hello world"},
+		{name: "word boundary", input: "hello wonderful world", opts: TruncateOptions{Length: 12}, expected: "hello..."},
+		{name: "sentence boundary", input: "First one. Second sentence here.", opts: TruncateOptions{Length: 20}, expected: "First one...."},
+		{name: "boundary too early", input: "a verylongwordthatkeepsgoing", opts: TruncateOptions{Length: 10}, expected: "a verylong..."},
+		{name: "custom ellipsis", input: "hello world", opts: TruncateOptions{Length: 8, Ellipsis: "…"}, expected: "hello…"},
+		{name: "combining marks", input: "e\u0301e\u0301e\u0301e\u0301", opts: TruncateOptions{Length: 2}, expected: "e\u0301e\u0301..."},
+		{name: "emoji sequence", input: "👨‍👩‍👧🇨🇳👍", opts: TruncateOptions{Length: 2}, expected: "👨‍👩‍👧🇨🇳..."},
+		{name: "cjk sentence", input: "今天天气很好。我们去公园散步吧", opts: TruncateOptions{Length: 10}, expected: "今天天气很好。..."},
+		{name: "display width cjk", input: "你好世界", opts: TruncateOptions{Length: 5, DisplayWidth: true}, expected: "你好..."},
+		{name: "display width fits", input: "你好", opts: TruncateOptions{Length: 4, DisplayWidth: true}, expected: "你好"},
+		{name: "display width mixed", input: "Go语言 rocks", opts: TruncateOptions{Length: 8, DisplayWidth: true}, expected: "Go语言..."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := TruncateText(tt.input, tt.opts); result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}